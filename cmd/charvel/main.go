@@ -20,38 +20,88 @@ func readCommand(cmdBuf *bufio.Reader) string {
 	return command
 }
 
-func processMetaCommand(command string, engine *sql.Engine) bool {
+func processMetaCommand(command string, engine *sql.Engine, activeTx **sql.Tx) bool {
+	parts := strings.Fields(command)
 	if command == "$exit" || command == "$quit" {
 		return true
-	} else if command == "$print" {
-		fmt.Println(engine.TableStateString())
+	} else if parts[0] == "$print" {
+		if len(parts) < 2 {
+			fmt.Println("Usage: $print <table>")
+			return false
+		}
+		fmt.Println(engine.TableStateString(parts[1]))
+		return false
+	} else if parts[0] == "$begin" {
+		if len(parts) < 2 {
+			fmt.Println("Usage: $begin <table>")
+			return false
+		}
+		if *activeTx != nil {
+			fmt.Println("A transaction is already in progress, $commit or $rollback it first")
+			return false
+		}
+		tx, err := engine.Begin(parts[1], true)
+		if err != nil {
+			fmt.Println("Could not begin transaction: ", err)
+			return false
+		}
+		*activeTx = tx
+		fmt.Println("Started transaction")
+		return false
+	} else if command == "$commit" {
+		if *activeTx == nil {
+			fmt.Println("No transaction in progress")
+			return false
+		}
+		if err := (*activeTx).Commit(); err != nil {
+			fmt.Println("Could not commit transaction: ", err)
+		}
+		*activeTx = nil
+		return false
+	} else if command == "$rollback" {
+		if *activeTx == nil {
+			fmt.Println("No transaction in progress")
+			return false
+		}
+		if err := (*activeTx).Rollback(); err != nil {
+			fmt.Println("Could not roll back transaction: ", err)
+		}
+		*activeTx = nil
 		return false
 	}
 	fmt.Println("UNRECOGNIZED COMMAND: ", command)
 	return false
 }
 
-func processCommand(command string, engine *sql.Engine) bool {
+func processCommand(command string, engine *sql.Engine, activeTx **sql.Tx) bool {
 	if command[0] == '$' {
-		return processMetaCommand(command, engine)
+		return processMetaCommand(command, engine, activeTx)
 	}
 	statement, err := engine.Prepare(command)
 	if err != nil {
 		fmt.Println("Error in statement construction: ", err)
 		return false
 	}
-	engine.Execute(statement)
+	if *activeTx != nil {
+		if err := engine.ExecuteInTx(*activeTx, statement); err != nil {
+			fmt.Println("Could not execute statement: ", err)
+		}
+	} else {
+		engine.Execute(statement)
+	}
 	return false
 }
 
 func main() {
 	fmt.Println("DB Terminal")
 	flag.Parse()
-	sqlEngine := sql.NewEngine()
+	sqlEngine := sql.NewEngine(sql.FormatVersion1)
+	defer sqlEngine.Close()
+	var activeTx *sql.Tx
 	commandBuffer := bufio.NewReader(os.Stdin)
 	for {
 		command := readCommand(commandBuffer)
-		quit := processCommand(command, sqlEngine)
+		quit := processCommand(command, sqlEngine, &activeTx)
 		if quit {
 			break
 		}
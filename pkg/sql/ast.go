@@ -0,0 +1,82 @@
+package sql
+
+// Stmt is the parsed representation of one SQL command, produced by
+// Parse. It replaces the old prefix-matching (isSelect/isInsert/...)
+// that used to live directly on Statement.
+type Stmt interface{ stmtNode() }
+
+const (
+	whereOpEquals  = "="
+	whereOpBetween = "between"
+)
+
+/*
+Placeholder stands in for a `?` or `:name` parameter until a
+Statement is bound. Pos is its index into Statement.bound, assigned
+in the order placeholders appear in the command; Name holds the
+`:name` text for named placeholders and is empty for positional ones.
+*/
+type Placeholder struct {
+	Name string
+	Pos  int
+}
+
+/*
+WhereClause is the subset of SQL's WHERE this engine understands:
+an equality or range test against the primary key column. Value/High
+hold the literal bound, or are placeholders until a Statement bound
+to this AST is resolved, in which case ValueParam/HighParam name
+which placeholder to pull them from.
+*/
+type WhereClause struct {
+	Column     string
+	Op         string
+	Value      int32
+	High       int32
+	ValueParam *Placeholder
+	HighParam  *Placeholder
+}
+
+/*CreateTableStmt is the AST for CREATE TABLE name (col type, ...)*/
+type CreateTableStmt struct {
+	Schema *Schema
+}
+
+/*
+InsertStmt is the AST for INSERT INTO table VALUES (...), with
+Values in schema column order
+*/
+type InsertStmt struct {
+	Table  string
+	Values []interface{}
+}
+
+/*SelectStmt is the AST for SELECT * FROM table [WHERE ...]*/
+type SelectStmt struct {
+	Table string
+	Where *WhereClause
+}
+
+/*
+UpdateStmt is the AST for UPDATE table [WHERE ...]. Execution of
+an update is still a stub, matching the engine's pre-parser behavior.
+*/
+type UpdateStmt struct {
+	Table string
+	Where *WhereClause
+}
+
+/*
+DeleteStmt is the AST for DELETE FROM table [WHERE ...]. Execution
+of a delete is still a stub, matching the engine's pre-parser behavior.
+*/
+type DeleteStmt struct {
+	Table string
+	Where *WhereClause
+}
+
+func (*CreateTableStmt) stmtNode() {}
+func (*InsertStmt) stmtNode()      {}
+func (*SelectStmt) stmtNode()      {}
+func (*UpdateStmt) stmtNode()      {}
+func (*DeleteStmt) stmtNode()      {}
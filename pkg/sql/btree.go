@@ -0,0 +1,547 @@
+package sql
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+)
+
+/*
+ErrDuplicateKey is returned by Table.Insert when a row with the
+same ID already exists in the tree
+*/
+var ErrDuplicateKey = errors.New("duplicate key")
+
+/*
+ErrRowNotFound is returned by Table.FetchRow when no row with the
+requested ID exists in the tree
+*/
+var ErrRowNotFound = errors.New("row not found")
+
+type pageType uint8
+
+const (
+	pageTypeInternal pageType = 0
+	pageTypeLeaf     pageType = 1
+)
+
+// Common node header: every page (other than the file header page)
+// starts with {pageType, numCells, parentPageNum}.
+const (
+	nodeTypeOffset = 0
+	nodeTypeSize   = 1
+	numCellsOffset = nodeTypeOffset + nodeTypeSize
+	numCellsSize   = 4
+	parentOffset   = numCellsOffset + numCellsSize
+	parentSize     = 4
+	nodeHeaderSize = parentOffset + parentSize
+)
+
+// Leaf nodes additionally carry a right-sibling page pointer so a
+// Cursor can walk leaves in key order without revisiting internal
+// nodes. A sibling of 0 means "no sibling" since page 0 is reserved
+// for the file header and can never be a leaf.
+const (
+	rightSiblingOffset = nodeHeaderSize
+	rightSiblingSize   = 4
+	leafHeaderSize     = rightSiblingOffset + rightSiblingSize
+)
+
+// Leaf cells are (id, serializedRow) pairs sorted by id. Unlike the
+// internal cell layout below, a leaf cell's size depends on the
+// table's schema (different tables have different row widths), so
+// it's computed at runtime instead of being a package const.
+const leafKeySize = idSize
+
+// Internal cells are (maxKey, childPageNum) pairs: maxKey is the
+// largest key in the subtree rooted at childPageNum when the cell was
+// written. A node's last cell is not raised when a later insert goes
+// past it; findLeafPage sends any key above every maxKey to the last
+// child. These never depend on row size, so they stay compile-time consts.
+const (
+	internalHeaderSize = nodeHeaderSize
+	internalKeySize    = idSize
+	internalChildSize  = 4
+	internalCellSize   = internalKeySize + internalChildSize
+	internalMaxCells   = (pageSize - internalHeaderSize) / internalCellSize
+	internalSplitCount = (internalMaxCells + 1) / 2
+)
+
+func getNodeType(page *[pageSize]byte) pageType { return pageType(page[nodeTypeOffset]) }
+func setNodeType(page *[pageSize]byte, t pageType) {
+	page[nodeTypeOffset] = byte(t)
+}
+
+func getNumCells(page *[pageSize]byte) int {
+	return int(binary.BigEndian.Uint32(page[numCellsOffset : numCellsOffset+numCellsSize]))
+}
+func setNumCells(page *[pageSize]byte, n int) {
+	binary.BigEndian.PutUint32(page[numCellsOffset:numCellsOffset+numCellsSize], uint32(n))
+}
+
+func getParent(page *[pageSize]byte) int {
+	return int(binary.BigEndian.Uint32(page[parentOffset : parentOffset+parentSize]))
+}
+func setParent(page *[pageSize]byte, parentPageNum int) {
+	binary.BigEndian.PutUint32(page[parentOffset:parentOffset+parentSize], uint32(parentPageNum))
+}
+
+func getRightSibling(page *[pageSize]byte) int {
+	return int(binary.BigEndian.Uint32(page[rightSiblingOffset : rightSiblingOffset+rightSiblingSize]))
+}
+func setRightSibling(page *[pageSize]byte, siblingPageNum int) {
+	binary.BigEndian.PutUint32(page[rightSiblingOffset:rightSiblingOffset+rightSiblingSize], uint32(siblingPageNum))
+}
+
+func leafCellSize(rowSize int) int   { return leafKeySize + rowSize }
+func leafMaxCells(rowSize int) int   { return (pageSize - leafHeaderSize) / leafCellSize(rowSize) }
+func leafSplitCount(rowSize int) int { return (leafMaxCells(rowSize) + 1) / 2 }
+func leafCellOffset(cellNum, rowSize int) int {
+	return leafHeaderSize + cellNum*leafCellSize(rowSize)
+}
+
+func getLeafKey(page *[pageSize]byte, cellNum, rowSize int) int32 {
+	off := leafCellOffset(cellNum, rowSize)
+	return int32(binary.BigEndian.Uint32(page[off : off+leafKeySize]))
+}
+func setLeafKey(page *[pageSize]byte, cellNum int, key int32, rowSize int) {
+	off := leafCellOffset(cellNum, rowSize)
+	binary.BigEndian.PutUint32(page[off:off+leafKeySize], uint32(key))
+}
+func getLeafValue(page *[pageSize]byte, cellNum, rowSize int) []byte {
+	off := leafCellOffset(cellNum, rowSize) + leafKeySize
+	return page[off : off+rowSize]
+}
+func setLeafValue(page *[pageSize]byte, cellNum int, value []byte, rowSize int) {
+	off := leafCellOffset(cellNum, rowSize) + leafKeySize
+	copy(page[off:off+rowSize], value)
+}
+func copyLeafCell(dst *[pageSize]byte, dstCell int, src *[pageSize]byte, srcCell, rowSize int) {
+	dstOff := leafCellOffset(dstCell, rowSize)
+	srcOff := leafCellOffset(srcCell, rowSize)
+	copy(dst[dstOff:dstOff+leafCellSize(rowSize)], src[srcOff:srcOff+leafCellSize(rowSize)])
+}
+
+func internalCellOffset(cellNum int) int { return internalHeaderSize + cellNum*internalCellSize }
+
+func getInternalKey(page *[pageSize]byte, cellNum int) int32 {
+	off := internalCellOffset(cellNum)
+	return int32(binary.BigEndian.Uint32(page[off : off+internalKeySize]))
+}
+func setInternalKey(page *[pageSize]byte, cellNum int, key int32) {
+	off := internalCellOffset(cellNum)
+	binary.BigEndian.PutUint32(page[off:off+internalKeySize], uint32(key))
+}
+func getInternalChild(page *[pageSize]byte, cellNum int) int {
+	off := internalCellOffset(cellNum) + internalKeySize
+	return int(binary.BigEndian.Uint32(page[off : off+internalChildSize]))
+}
+func setInternalChild(page *[pageSize]byte, cellNum int, childPageNum int) {
+	off := internalCellOffset(cellNum) + internalKeySize
+	binary.BigEndian.PutUint32(page[off:off+internalChildSize], uint32(childPageNum))
+}
+
+/*
+pageStore is the page-level abstraction the B+Tree algorithms are
+written against: a *Pager satisfies it directly for untransacted
+access, while a *Tx satisfies it with copy-on-write semantics so
+writes stay invisible to other readers until Commit. Schema is part
+of the interface because a leaf cell's size depends on which table's
+rows it's holding.
+*/
+type pageStore interface {
+	GetPage(pageNum int) *[pageSize]byte
+	AllocatePage() int
+	markDirty(pageNum int)
+	RootPageNum() int
+	SetRootPageNum(pageNum int)
+	Schema() *Schema
+	FormatVersion() int
+	IncrementRowCount()
+}
+
+/*
+Table is the storage engine, managing how
+records are organized into a B+Tree keyed on Row.ID()
+and serialized into pages on disk. It talks to its
+pager directly, with no transactional isolation -
+use Engine.Update/Engine.View for that.
+*/
+type Table struct {
+	pager  *Pager
+	schema *Schema
+}
+
+/*
+NewTable is a constructor for the table object.
+The underlying pager takes care of creating a fresh
+root leaf page (new file) or validating the header of
+an existing one, using defaultFormatVersion only if dbFileName
+doesn't already exist - schema describes how rows in this table
+are laid out, and is independent of which on-disk format they're
+stored in.
+*/
+func NewTable(dbFileName string, schema *Schema, defaultFormatVersion int) *Table {
+	pager := NewPager(dbFileName, defaultFormatVersion)
+	if pager.FormatVersion() == FormatVersion1 && pager.RowSize() == 0 {
+		pager.SetRowSize(schema.RowSize())
+	}
+	return &Table{pager: pager, schema: schema}
+}
+
+func (t *Table) GetPage(pageNum int) *[pageSize]byte { return t.pager.GetPage(pageNum) }
+func (t *Table) AllocatePage() int                   { return t.pager.AllocatePage() }
+func (t *Table) markDirty(pageNum int)               { t.pager.markDirty(pageNum) }
+func (t *Table) RootPageNum() int                    { return t.pager.RootPageNum() }
+func (t *Table) SetRootPageNum(pageNum int)          { t.pager.SetRootPageNum(pageNum) }
+func (t *Table) Schema() *Schema                     { return t.schema }
+func (t *Table) FormatVersion() int                  { return t.pager.FormatVersion() }
+func (t *Table) IncrementRowCount()                  { t.pager.IncrementRowCount() }
+
+/*
+Insert descends from the root to the target leaf and adds the
+row in sorted position, splitting the leaf (and cascading into
+parents, possibly allocating a new root) if it's full. A row whose
+ID already exists is rejected with ErrDuplicateKey.
+*/
+func (t *Table) Insert(row *Row) error { return insertRow(t, row) }
+
+/*
+FetchRow descends the tree looking for an exact ID match,
+returning ErrRowNotFound if no such row exists
+*/
+func (t *Table) FetchRow(id int32) (*Row, error) { return fetchRow(t, id) }
+
+/*
+ToString is mostly for debugging
+by dumping the current state of the table
+to the output, walking leaves in key order
+*/
+func (t *Table) ToString() string { return storeToString(t) }
+
+/*
+Close flushes the whole table to disk
+and closes the db file
+*/
+func (t *Table) Close() {
+	t.pager.Close()
+}
+
+func findLeafPage(store pageStore, pageNum int, key int32) int {
+	page := store.GetPage(pageNum)
+	if getNodeType(page) == pageTypeLeaf {
+		return pageNum
+	}
+	numKeys := getNumCells(page)
+	idx := sort.Search(numKeys, func(i int) bool { return getInternalKey(page, i) >= key })
+	if idx == numKeys {
+		idx = numKeys - 1
+	}
+	return findLeafPage(store, getInternalChild(page, idx), key)
+}
+
+func leftmostLeaf(store pageStore, pageNum int) int {
+	page := store.GetPage(pageNum)
+	if getNodeType(page) == pageTypeLeaf {
+		return pageNum
+	}
+	return leftmostLeaf(store, getInternalChild(page, 0))
+}
+
+func maxKeyOf(store pageStore, pageNum int) int32 {
+	page := store.GetPage(pageNum)
+	if getNodeType(page) == pageTypeLeaf {
+		if store.FormatVersion() == FormatVersion2 {
+			return v2GetSlotKey(page, getNumCells(page)-1)
+		}
+		rowSize := store.Schema().RowSize()
+		return getLeafKey(page, getNumCells(page)-1, rowSize)
+	}
+	return getInternalKey(page, getNumCells(page)-1)
+}
+
+func insertRow(store pageStore, row *Row) error {
+	if store.FormatVersion() == FormatVersion2 {
+		if err := insertRowV2(store, row); err != nil {
+			return err
+		}
+		store.IncrementRowCount()
+		return nil
+	}
+
+	rowSize := store.Schema().RowSize()
+	leafPageNum := findLeafPage(store, store.RootPageNum(), row.ID())
+	page := store.GetPage(leafPageNum)
+	numCells := getNumCells(page)
+	idx := sort.Search(numCells, func(i int) bool { return getLeafKey(page, i, rowSize) >= row.ID() })
+	if idx < numCells && getLeafKey(page, idx, rowSize) == row.ID() {
+		return ErrDuplicateKey
+	}
+
+	if numCells >= leafMaxCells(rowSize) {
+		splitLeafAndInsert(store, leafPageNum, idx, row)
+		store.IncrementRowCount()
+		return nil
+	}
+
+	for i := numCells; i > idx; i-- {
+		copyLeafCell(page, i, page, i-1, rowSize)
+	}
+	setLeafKey(page, idx, row.ID(), rowSize)
+	setLeafValue(page, idx, row.Serialize().Bytes(), rowSize)
+	setNumCells(page, numCells+1)
+	store.markDirty(leafPageNum)
+	store.IncrementRowCount()
+	return nil
+}
+
+func splitLeafAndInsert(store pageStore, oldPageNum int, insertIdx int, row *Row) {
+	rowSize := store.Schema().RowSize()
+	oldPage := store.GetPage(oldPageNum)
+	newPageNum := store.AllocatePage()
+	newPage := store.GetPage(newPageNum)
+	setNodeType(newPage, pageTypeLeaf)
+	setParent(newPage, getParent(oldPage))
+
+	splitCount := leafSplitCount(rowSize)
+	total := leafMaxCells(rowSize) + 1
+	for i := total - 1; i >= 0; i-- {
+		destPage, destIdx := oldPage, i
+		if i >= splitCount {
+			destPage, destIdx = newPage, i-splitCount
+		}
+		if i == insertIdx {
+			setLeafKey(destPage, destIdx, row.ID(), rowSize)
+			setLeafValue(destPage, destIdx, row.Serialize().Bytes(), rowSize)
+			continue
+		}
+		srcIdx := i
+		if i > insertIdx {
+			srcIdx = i - 1
+		}
+		copyLeafCell(destPage, destIdx, oldPage, srcIdx, rowSize)
+	}
+
+	setNumCells(oldPage, splitCount)
+	setNumCells(newPage, total-splitCount)
+	setRightSibling(newPage, getRightSibling(oldPage))
+	setRightSibling(oldPage, newPageNum)
+
+	store.markDirty(oldPageNum)
+	store.markDirty(newPageNum)
+
+	splitKey := getLeafKey(oldPage, splitCount-1, rowSize)
+	insertIntoParent(store, oldPageNum, newPageNum, splitKey)
+}
+
+func insertIntoParent(store pageStore, leftPageNum, rightPageNum int, leftMaxKey int32) {
+	if leftPageNum == store.RootPageNum() {
+		createNewRoot(store, leftPageNum, rightPageNum, leftMaxKey)
+		return
+	}
+
+	leftPage := store.GetPage(leftPageNum)
+	parentPageNum := getParent(leftPage)
+	rightPage := store.GetPage(rightPageNum)
+	setParent(rightPage, parentPageNum)
+	store.markDirty(rightPageNum)
+
+	parentPage := store.GetPage(parentPageNum)
+	numKeys := getNumCells(parentPage)
+	leftIdx := 0
+	for leftIdx < numKeys && getInternalChild(parentPage, leftIdx) != leftPageNum {
+		leftIdx++
+	}
+	setInternalKey(parentPage, leftIdx, leftMaxKey)
+	store.markDirty(parentPageNum)
+
+	rightMaxKey := maxKeyOf(store, rightPageNum)
+	if numKeys >= internalMaxCells {
+		splitInternalAndInsert(store, parentPageNum, leftIdx+1, rightMaxKey, rightPageNum)
+		return
+	}
+
+	for i := numKeys; i > leftIdx+1; i-- {
+		setInternalKey(parentPage, i, getInternalKey(parentPage, i-1))
+		setInternalChild(parentPage, i, getInternalChild(parentPage, i-1))
+	}
+	setInternalKey(parentPage, leftIdx+1, rightMaxKey)
+	setInternalChild(parentPage, leftIdx+1, rightPageNum)
+	setNumCells(parentPage, numKeys+1)
+}
+
+func splitInternalAndInsert(store pageStore, oldPageNum int, insertIdx int, insertKey int32, insertChild int) {
+	oldPage := store.GetPage(oldPageNum)
+	newPageNum := store.AllocatePage()
+	newPage := store.GetPage(newPageNum)
+	setNodeType(newPage, pageTypeInternal)
+	setParent(newPage, getParent(oldPage))
+
+	total := internalMaxCells + 1
+	keys := make([]int32, total)
+	children := make([]int, total)
+	for i := 0; i < total; i++ {
+		if i == insertIdx {
+			keys[i], children[i] = insertKey, insertChild
+			continue
+		}
+		srcIdx := i
+		if i > insertIdx {
+			srcIdx = i - 1
+		}
+		keys[i] = getInternalKey(oldPage, srcIdx)
+		children[i] = getInternalChild(oldPage, srcIdx)
+	}
+
+	for i := 0; i < internalSplitCount; i++ {
+		setInternalKey(oldPage, i, keys[i])
+		setInternalChild(oldPage, i, children[i])
+	}
+	setNumCells(oldPage, internalSplitCount)
+
+	for i := internalSplitCount; i < total; i++ {
+		childPage := store.GetPage(children[i])
+		setParent(childPage, newPageNum)
+		store.markDirty(children[i])
+		destIdx := i - internalSplitCount
+		setInternalKey(newPage, destIdx, keys[i])
+		setInternalChild(newPage, destIdx, children[i])
+	}
+	setNumCells(newPage, total-internalSplitCount)
+
+	store.markDirty(oldPageNum)
+	store.markDirty(newPageNum)
+
+	splitKey := keys[internalSplitCount-1]
+	insertIntoParent(store, oldPageNum, newPageNum, splitKey)
+}
+
+func createNewRoot(store pageStore, leftPageNum, rightPageNum int, leftMaxKey int32) {
+	newRootPageNum := store.AllocatePage()
+	newRootPage := store.GetPage(newRootPageNum)
+	setNodeType(newRootPage, pageTypeInternal)
+	setParent(newRootPage, 0)
+	setNumCells(newRootPage, 2)
+	setInternalKey(newRootPage, 0, leftMaxKey)
+	setInternalChild(newRootPage, 0, leftPageNum)
+	setInternalKey(newRootPage, 1, maxKeyOf(store, rightPageNum))
+	setInternalChild(newRootPage, 1, rightPageNum)
+
+	setParent(store.GetPage(leftPageNum), newRootPageNum)
+	setParent(store.GetPage(rightPageNum), newRootPageNum)
+
+	store.markDirty(leftPageNum)
+	store.markDirty(rightPageNum)
+	store.markDirty(newRootPageNum)
+	store.SetRootPageNum(newRootPageNum)
+}
+
+func fetchRow(store pageStore, id int32) (*Row, error) {
+	leafPageNum := findLeafPage(store, store.RootPageNum(), id)
+	page := store.GetPage(leafPageNum)
+	if store.FormatVersion() == FormatVersion2 {
+		return fetchRowV2(store.Schema(), page, id)
+	}
+	rowSize := store.Schema().RowSize()
+	numCells := getNumCells(page)
+	idx := sort.Search(numCells, func(i int) bool { return getLeafKey(page, i, rowSize) >= id })
+	if idx >= numCells || getLeafKey(page, idx, rowSize) != id {
+		return nil, ErrRowNotFound
+	}
+	return deserializeLeafValue(store.Schema(), getLeafValue(page, idx, rowSize)), nil
+}
+
+func deserializeLeafValue(schema *Schema, valueBytes []byte) *Row {
+	fixed := make([]byte, schema.RowSize())
+	copy(fixed, valueBytes)
+	return DeserializeRow(schema, fixed)
+}
+
+func storeToString(store pageStore) string {
+	builder := strings.Builder{}
+	cursor := NewCursor(store, "iterator")
+	for cursor.Advance() {
+		builder.WriteString(cursor.GetRow().ToString())
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+/*
+Cursor is a way to walk the leaves of the tree in key order,
+following rightSibling pointers instead of re-descending from
+the root for every row
+*/
+type Cursor struct {
+	store   pageStore
+	pageNum int
+	cellNum int
+}
+
+/*
+NewCursor positions a cursor at the leftmost leaf. The
+"iterator" mode starts one cell before the first row, expecting
+a for loop to call Advance before reading anything; "start"
+positions directly on the first row.
+*/
+func NewCursor(store pageStore, mode string) *Cursor {
+	cursor := &Cursor{store: store, pageNum: leftmostLeaf(store, store.RootPageNum())}
+	if mode == "iterator" {
+		cursor.cellNum = -1
+	}
+	return cursor
+}
+
+/*
+NewCursorAt descends the tree to seek the first row with id >= key,
+the way findLeafPage already does for a point lookup, and positions
+the cursor one cell before it - "iterator" mode relative to that
+point - so a ranged scan (e.g. BETWEEN) can Advance from there in key
+order without walking every leaf between the leftmost one and key.
+*/
+func NewCursorAt(store pageStore, key int32) *Cursor {
+	pageNum := findLeafPage(store, store.RootPageNum(), key)
+	idx := leafSearch(store, pageNum, key)
+	return &Cursor{store: store, pageNum: pageNum, cellNum: idx - 1}
+}
+
+func leafSearch(store pageStore, pageNum int, key int32) int {
+	page := store.GetPage(pageNum)
+	numCells := getNumCells(page)
+	if store.FormatVersion() == FormatVersion2 {
+		return sort.Search(numCells, func(i int) bool { return v2GetSlotKey(page, i) >= key })
+	}
+	rowSize := store.Schema().RowSize()
+	return sort.Search(numCells, func(i int) bool { return getLeafKey(page, i, rowSize) >= key })
+}
+
+/*
+Advance moves the cursor to the next row, crossing into the
+right sibling leaf when the current page is exhausted, and
+returns false once there are no more rows to visit
+*/
+func (c *Cursor) Advance() bool {
+	c.cellNum++
+	page := c.store.GetPage(c.pageNum)
+	if c.cellNum < getNumCells(page) {
+		return true
+	}
+	nextPageNum := getRightSibling(page)
+	if nextPageNum == 0 {
+		return false
+	}
+	c.pageNum = nextPageNum
+	c.cellNum = 0
+	return getNumCells(c.store.GetPage(c.pageNum)) > 0
+}
+
+/*GetRow rehydrates the Row at the cursor's current position*/
+func (c *Cursor) GetRow() *Row {
+	page := c.store.GetPage(c.pageNum)
+	if c.store.FormatVersion() == FormatVersion2 {
+		return DeserializeRowV2(c.store.Schema(), v2GetSlotCell(page, c.cellNum))
+	}
+	rowSize := c.store.Schema().RowSize()
+	return deserializeLeafValue(c.store.Schema(), getLeafValue(page, c.cellNum, rowSize))
+}
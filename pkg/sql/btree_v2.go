@@ -0,0 +1,183 @@
+package sql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+/*
+FormatVersion2 leaves use a slotted-page layout instead of the
+fixed-stride cells FormatVersion1 leaves use: since rows no longer
+pad/truncate VARCHAR/TEXT to a fixed width, cells vary in size and
+can't be indexed by cellNum*cellSize anymore. Instead, a slot array
+grows forward from the leaf header, one fixed-size {key, cellOffset,
+cellLength} entry per row, while the variable-length cell bodies
+they point at are packed in from the end of the page. Internal
+nodes, findLeafPage, insertIntoParent and friends are all unchanged -
+only the leaf-level code in this file knows about the layout.
+
+Inserting rewrites a leaf's whole slot array and cell region from
+scratch rather than shifting bytes in place to make room. That's
+more work per insert than v1's memmove, but a leaf is at most one
+page, and it keeps split-or-not a single "does it fit" check instead
+of tracking a free-space pointer and reclaiming gaps left by deletes.
+*/
+const (
+	v2SlotKeySize    = idSize
+	v2SlotOffsetSize = 2
+	v2SlotLengthSize = 2
+	v2SlotSize       = v2SlotKeySize + v2SlotOffsetSize + v2SlotLengthSize
+)
+
+func v2SlotOffset(slotNum int) int { return leafHeaderSize + slotNum*v2SlotSize }
+
+func v2GetSlotKey(page *[pageSize]byte, slotNum int) int32 {
+	off := v2SlotOffset(slotNum)
+	return int32(binary.BigEndian.Uint32(page[off : off+v2SlotKeySize]))
+}
+
+func v2GetSlotCell(page *[pageSize]byte, slotNum int) []byte {
+	off := v2SlotOffset(slotNum) + v2SlotKeySize
+	cellOffset := int(binary.BigEndian.Uint16(page[off : off+v2SlotOffsetSize]))
+	cellLength := int(binary.BigEndian.Uint16(page[off+v2SlotOffsetSize : off+v2SlotOffsetSize+v2SlotLengthSize]))
+	return page[cellOffset : cellOffset+cellLength]
+}
+
+// v2Row is a leaf row held in memory while a leaf's slot array and
+// cell region are being rebuilt from scratch.
+type v2Row struct {
+	key  int32
+	cell []byte
+}
+
+func v2ReadRows(page *[pageSize]byte) []v2Row {
+	numCells := getNumCells(page)
+	rows := make([]v2Row, numCells)
+	for i := 0; i < numCells; i++ {
+		cell := v2GetSlotCell(page, i)
+		rows[i] = v2Row{key: v2GetSlotKey(page, i), cell: append([]byte(nil), cell...)}
+	}
+	return rows
+}
+
+/*
+v2WriteRows rewrites a leaf page's slot array and cell bodies from
+scratch given the full sorted set of rows it should hold, returning
+false (without writing anything to the page) if they don't fit in
+one page, so the caller knows to split instead.
+*/
+func v2WriteRows(page *[pageSize]byte, rows []v2Row) bool {
+	cellBytes := 0
+	for _, row := range rows {
+		cellBytes += len(row.cell)
+	}
+	if !rowFitsInLeaf(len(rows), cellBytes) {
+		return false
+	}
+	cellEnd := pageSize
+	for i, row := range rows {
+		cellEnd -= len(row.cell)
+		copy(page[cellEnd:cellEnd+len(row.cell)], row.cell)
+		slotOff := v2SlotOffset(i)
+		binary.BigEndian.PutUint32(page[slotOff:slotOff+v2SlotKeySize], uint32(row.key))
+		offOff := slotOff + v2SlotKeySize
+		binary.BigEndian.PutUint16(page[offOff:offOff+v2SlotOffsetSize], uint16(cellEnd))
+		binary.BigEndian.PutUint16(page[offOff+v2SlotOffsetSize:offOff+v2SlotOffsetSize+v2SlotLengthSize], uint16(len(row.cell)))
+	}
+	setNumCells(page, len(rows))
+	return true
+}
+
+func insertRowV2(store pageStore, row *Row) error {
+	leafPageNum := findLeafPage(store, store.RootPageNum(), row.ID())
+	page := store.GetPage(leafPageNum)
+	rows := v2ReadRows(page)
+	idx := sort.Search(len(rows), func(i int) bool { return rows[i].key >= row.ID() })
+	if idx < len(rows) && rows[idx].key == row.ID() {
+		return ErrDuplicateKey
+	}
+
+	rows = append(rows, v2Row{})
+	copy(rows[idx+1:], rows[idx:])
+	rows[idx] = v2Row{key: row.ID(), cell: row.SerializeV2()}
+
+	if v2WriteRows(page, rows) {
+		store.markDirty(leafPageNum)
+		return nil
+	}
+	return splitLeafAndInsertV2(store, leafPageNum, rows)
+}
+
+func rowFitsInLeaf(numRows int, cellBytes int) bool {
+	return v2SlotOffset(numRows) <= pageSize-cellBytes
+}
+
+/*
+v2FindSplitIndex picks where to divide rows between the two leaves a
+split produces, by accumulated cell size rather than row count -
+FormatVersion2 rows vary widely in size, so an even row-count split
+can easily leave one half overflowing a page while the other is
+nearly empty. It returns an error, rather than letting the caller
+write past a page boundary, if even one row doesn't fit in a leaf by
+itself, or if no two-way split of this row set fits in two pages.
+*/
+func v2FindSplitIndex(rows []v2Row) (int, error) {
+	prefixBytes := 0
+	splitCount := 0
+	for i, row := range rows {
+		if !rowFitsInLeaf(i+1, prefixBytes+len(row.cell)) {
+			break
+		}
+		prefixBytes += len(row.cell)
+		splitCount = i + 1
+	}
+	if splitCount == 0 {
+		return 0, fmt.Errorf("a %d-byte row is too large to fit in a %d-byte leaf page", len(rows[0].cell), pageSize)
+	}
+
+	remainderBytes := 0
+	for _, row := range rows[splitCount:] {
+		remainderBytes += len(row.cell)
+	}
+	if !rowFitsInLeaf(len(rows)-splitCount, remainderBytes) {
+		return 0, fmt.Errorf("this row set doesn't fit across a two-way leaf split")
+	}
+	return splitCount, nil
+}
+
+func splitLeafAndInsertV2(store pageStore, oldPageNum int, rows []v2Row) error {
+	splitCount, err := v2FindSplitIndex(rows)
+	if err != nil {
+		return err
+	}
+
+	oldPage := store.GetPage(oldPageNum)
+	newPageNum := store.AllocatePage()
+	newPage := store.GetPage(newPageNum)
+	setNodeType(newPage, pageTypeLeaf)
+	setParent(newPage, getParent(oldPage))
+	oldRightSibling := getRightSibling(oldPage)
+
+	if !v2WriteRows(oldPage, rows[:splitCount]) || !v2WriteRows(newPage, rows[splitCount:]) {
+		return fmt.Errorf("split leaf rows unexpectedly didn't fit after v2FindSplitIndex chose a fitting split")
+	}
+	setRightSibling(newPage, oldRightSibling)
+	setRightSibling(oldPage, newPageNum)
+
+	store.markDirty(oldPageNum)
+	store.markDirty(newPageNum)
+
+	splitKey := rows[splitCount-1].key
+	insertIntoParent(store, oldPageNum, newPageNum, splitKey)
+	return nil
+}
+
+func fetchRowV2(schema *Schema, page *[pageSize]byte, id int32) (*Row, error) {
+	numCells := getNumCells(page)
+	idx := sort.Search(numCells, func(i int) bool { return v2GetSlotKey(page, i) >= id })
+	if idx >= numCells || v2GetSlotKey(page, idx) != id {
+		return nil, ErrRowNotFound
+	}
+	return DeserializeRowV2(schema, v2GetSlotCell(page, idx)), nil
+}
@@ -0,0 +1,101 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+const catalogFile = "./charvel_catalog.db"
+
+/*
+catalogEntry is one table's row in the catalog: where its data
+file lives, and the Schema that describes it
+*/
+type catalogEntry struct {
+	fileName string
+	schema   *Schema
+}
+
+/*
+catalog is the bootstrap directory of tables an Engine knows
+about. It persists every entry on page 0 of a single reserved file,
+so a restarted Engine can rebuild its table map without the caller
+re-issuing every CREATE TABLE. It is deliberately simple - one page,
+no btree - since it only ever holds a handful of table definitions.
+*/
+type catalog struct {
+	file *os.File
+}
+
+func openCatalog(path string) *catalog {
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		log.Fatal("Could not open catalog file: ", err)
+	}
+	return &catalog{file: fd}
+}
+
+func (c *catalog) close() {
+	c.file.Close()
+}
+
+/*
+load reads every catalog entry out of page 0, keyed by table
+name. A brand new (empty) catalog file loads as zero entries.
+*/
+func (c *catalog) load() (map[string]*catalogEntry, error) {
+	entries := make(map[string]*catalogEntry)
+	info, err := c.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == 0 {
+		return entries, nil
+	}
+
+	page := make([]byte, pageSize)
+	if _, err := c.file.ReadAt(page, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	r := bytes.NewReader(page)
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(count); i++ {
+		fileName, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := deserializeSchema(r)
+		if err != nil {
+			return nil, err
+		}
+		entries[schema.TableName] = &catalogEntry{fileName: fileName, schema: schema}
+	}
+	return entries, nil
+}
+
+/*save rewrites page 0 with the full set of catalog entries*/
+func (c *catalog) save(entries map[string]*catalogEntry) error {
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, uint16(len(entries)))
+	for _, entry := range entries {
+		writeString(buf, entry.fileName)
+		entry.schema.serializeInto(buf)
+	}
+	if buf.Len() > pageSize {
+		return errors.New("catalog page overflow: too many tables/columns for the bootstrap catalog")
+	}
+
+	page := make([]byte, pageSize)
+	copy(page, buf.Bytes())
+	if _, err := c.file.WriteAt(page, 0); err != nil {
+		return err
+	}
+	return c.file.Sync()
+}
@@ -0,0 +1,270 @@
+package sql
+
+import (
+	"os"
+	"testing"
+)
+
+const testAccountsFile = "./test_accounts.db"
+const testWidgetsFile = "./test_widgets.db"
+
+func clearEngineTestFiles() {
+	os.Remove(testCatalogFile)
+	os.Remove(walFileName(testCatalogFile))
+	os.Remove(testAccountsFile)
+	os.Remove(walFileName(testAccountsFile))
+	os.Remove(testWidgetsFile)
+	os.Remove(walFileName(testWidgetsFile))
+	// CreateTable names a table's data file after the table itself
+	// ("./accounts.db", not testAccountsFile), so tests that exercise
+	// CreateTable need their real data files swept too.
+	for _, fileName := range []string{"./accounts.db", "./widgets.db"} {
+		os.Remove(fileName)
+		os.Remove(walFileName(fileName))
+	}
+}
+
+const testCatalogFile = "./test_catalog.db"
+
+func newCatalogEngine(t *testing.T) *Engine {
+	clearEngineTestFiles()
+	t.Cleanup(clearEngineTestFiles)
+	return &Engine{
+		catalog:              openCatalog(testCatalogFile),
+		entries:              make(map[string]*catalogEntry),
+		tables:               make(map[string]*Table),
+		defaultFormatVersion: FormatVersion1,
+	}
+}
+
+func TestCreateTableRegistersTable(t *testing.T) {
+	engine := newCatalogEngine(t)
+	schema := &Schema{
+		TableName: "widgets",
+		Columns: []Column{
+			{Name: "id", Type: ColumnInt32, PrimaryKey: true},
+			{Name: "label", Type: ColumnVarchar, Size: 16},
+		},
+	}
+	if err := engine.CreateTable(schema); err != nil {
+		t.Fatal("Failed to create table", err)
+	}
+	if err := engine.Update("widgets", func(tx *Tx) error {
+		row, err := NewRowWithSchema(schema, int32(1), "first")
+		if err != nil {
+			return err
+		}
+		return tx.Insert(row)
+	}); err != nil {
+		t.Error("Failed to insert into newly created table", err)
+	}
+	if err := engine.View("widgets", func(tx *Tx) error {
+		row, err := tx.FetchRow(1)
+		if err != nil {
+			return err
+		}
+		if row.Values[1].(string) != "first" {
+			t.Error("Unexpected row contents", row)
+		}
+		return nil
+	}); err != nil {
+		t.Error("Failed to fetch row back", err)
+	}
+}
+
+func TestCreateTableRejectsDuplicateName(t *testing.T) {
+	engine := newCatalogEngine(t)
+	schema := &Schema{TableName: "widgets", Columns: []Column{{Name: "id", Type: ColumnInt32, PrimaryKey: true}}}
+	if err := engine.CreateTable(schema); err != nil {
+		t.Fatal("Failed to create table", err)
+	}
+	if err := engine.CreateTable(schema); err == nil {
+		t.Error("Expected an error creating a table that already exists")
+	}
+}
+
+func TestCreateTableRejectsNonInt32PrimaryKey(t *testing.T) {
+	engine := newCatalogEngine(t)
+	schema := &Schema{
+		TableName: "widgets",
+		Columns: []Column{
+			{Name: "name", Type: ColumnVarchar, Size: 10, PrimaryKey: true},
+		},
+	}
+	if err := engine.CreateTable(schema); err == nil {
+		t.Error("Expected CreateTable to reject a non-INT32 primary key column")
+	}
+}
+
+func TestTwoTablesSurviveEngineRestart(t *testing.T) {
+	engine := newCatalogEngine(t)
+	accounts := &Schema{
+		TableName: "accounts",
+		Columns: []Column{
+			{Name: "id", Type: ColumnInt32, PrimaryKey: true},
+			{Name: "name", Type: ColumnVarchar, Size: 32},
+		},
+	}
+	widgets := &Schema{
+		TableName: "widgets",
+		Columns: []Column{
+			{Name: "id", Type: ColumnInt32, PrimaryKey: true},
+			{Name: "label", Type: ColumnVarchar, Size: 16},
+		},
+	}
+	if err := engine.CreateTable(accounts); err != nil {
+		t.Fatal("Failed to create accounts table", err)
+	}
+	if err := engine.CreateTable(widgets); err != nil {
+		t.Fatal("Failed to create widgets table", err)
+	}
+	if err := engine.Update("accounts", func(tx *Tx) error {
+		row, err := NewRowWithSchema(accounts, int32(1), "alice")
+		if err != nil {
+			return err
+		}
+		return tx.Insert(row)
+	}); err != nil {
+		t.Error("Failed to insert into accounts", err)
+	}
+	engine.Close()
+
+	restarted := &Engine{catalog: openCatalog(testCatalogFile)}
+	entries, err := restarted.catalog.load()
+	if err != nil {
+		t.Fatal("Failed to reload catalog", err)
+	}
+	restarted.entries = entries
+	restarted.tables = make(map[string]*Table, len(entries))
+	for name, entry := range entries {
+		restarted.tables[name] = NewTable(entry.fileName, entry.schema, FormatVersion1)
+	}
+	defer restarted.Close()
+
+	if _, ok := restarted.tables["accounts"]; !ok {
+		t.Error("Expected accounts table to survive restart")
+	}
+	if _, ok := restarted.tables["widgets"]; !ok {
+		t.Error("Expected widgets table to survive restart")
+	}
+	if err := restarted.View("accounts", func(tx *Tx) error {
+		row, err := tx.FetchRow(1)
+		if err != nil {
+			return err
+		}
+		if row.Values[1].(string) != "alice" {
+			t.Error("Recovered row has unexpected contents", row)
+		}
+		return nil
+	}); err != nil {
+		t.Error("Failed to fetch recovered row", err)
+	}
+}
+
+func TestExecuteInTxRejectsStatementForAnotherTable(t *testing.T) {
+	engine := newCatalogEngine(t)
+	accounts := &Schema{
+		TableName: "accounts",
+		Columns: []Column{
+			{Name: "id", Type: ColumnInt32, PrimaryKey: true},
+			{Name: "name", Type: ColumnVarchar, Size: 32},
+		},
+	}
+	widgets := &Schema{
+		TableName: "widgets",
+		Columns: []Column{
+			{Name: "id", Type: ColumnInt32, PrimaryKey: true},
+			{Name: "label", Type: ColumnVarchar, Size: 16},
+		},
+	}
+	if err := engine.CreateTable(accounts); err != nil {
+		t.Fatal("Failed to create accounts table", err)
+	}
+	if err := engine.CreateTable(widgets); err != nil {
+		t.Fatal("Failed to create widgets table", err)
+	}
+
+	tx, err := engine.Begin("accounts", true)
+	if err != nil {
+		t.Fatal("Failed to begin transaction against accounts", err)
+	}
+	defer tx.Rollback()
+
+	statement, err := engine.Prepare(`INSERT INTO widgets VALUES (1, "first")`)
+	if err != nil {
+		t.Fatal("Failed to prepare INSERT", err)
+	}
+	if err := engine.ExecuteInTx(tx, statement); err == nil {
+		t.Error("Expected ExecuteInTx to reject a statement naming a different table than the transaction's")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Error("Failed to commit transaction", err)
+	}
+
+	if err := engine.View("widgets", func(tx *Tx) error {
+		_, err := tx.FetchRow(1)
+		if err != ErrRowNotFound {
+			t.Error("Row should not have been inserted into widgets", err)
+		}
+		return nil
+	}); err != nil {
+		t.Error("Failed to view widgets", err)
+	}
+}
+
+func TestExecuteInTxAppliesStatementForItsOwnTable(t *testing.T) {
+	engine := newAccountsEngine(t)
+	tx, err := engine.Begin("accounts", true)
+	if err != nil {
+		t.Fatal("Failed to begin transaction against accounts", err)
+	}
+
+	statement, err := engine.Prepare(`INSERT INTO accounts VALUES (1, "alice")`)
+	if err != nil {
+		t.Fatal("Failed to prepare INSERT", err)
+	}
+	if err := engine.ExecuteInTx(tx, statement); err != nil {
+		t.Fatal("Failed to execute statement against its own table", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal("Failed to commit transaction", err)
+	}
+
+	if err := engine.View("accounts", func(tx *Tx) error {
+		row, err := tx.FetchRow(1)
+		if err != nil {
+			return err
+		}
+		if row.Values[1].(string) != "alice" {
+			t.Error("Unexpected row contents", row)
+		}
+		return nil
+	}); err != nil {
+		t.Error("Failed to fetch inserted row", err)
+	}
+}
+
+func TestEngineExecuteDispatchesCreateInsertSelect(t *testing.T) {
+	engine := newCatalogEngine(t)
+	statement, err := engine.Prepare("CREATE TABLE accounts (id INT32 PRIMARY KEY, name VARCHAR(32))")
+	if err != nil {
+		t.Fatal("Failed to prepare CREATE TABLE", err)
+	}
+	engine.Execute(statement)
+	if _, ok := engine.tables["accounts"]; !ok {
+		t.Fatal("Expected CREATE TABLE to register the table")
+	}
+
+	statement, err = engine.Prepare(`INSERT INTO accounts VALUES (1, "alice")`)
+	if err != nil {
+		t.Fatal("Failed to prepare INSERT", err)
+	}
+	engine.Execute(statement)
+
+	if err := engine.View("accounts", func(tx *Tx) error {
+		_, err := tx.FetchRow(1)
+		return err
+	}); err != nil {
+		t.Error("Expected the inserted row to be fetchable", err)
+	}
+}
@@ -0,0 +1,370 @@
+package sql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Page layout constants. Page 0 is reserved for the file header; all
+// btree nodes (leaf or internal) live at page 1 and above.
+const pageSize = 4096
+const tableMaxPages = 100
+const dbFile = "./charvel.db"
+const fileHeaderPageNum = 0
+const firstDataPageNum = 1
+
+// File header layout (lives entirely inside page 0). Following the
+// approach cznic/ql's V2 back end took, the first 100 bytes are a
+// self-describing, versioned header - magic, format version, page
+// size, row size, root page num, row count, feature flags, then
+// padding reserved for fields a future format might need - so a
+// file can always be identified and sized up without first parsing
+// anything format-specific. Everything Pager needs that predates
+// this versioning (the free list head, the next-page counter, the
+// WAL checkpoint offset) lives just past it, at offset 100 on, since
+// none of those are part of the versioned contract.
+const headerMagic = "CHRVL\x00"
+const headerMagicSize = 6
+const headerFormatVersionOffset = headerMagicSize
+const headerFormatVersionSize = 2
+const headerPageSizeOffset = headerFormatVersionOffset + headerFormatVersionSize
+const headerPageSizeSize = 4
+const headerRowSizeOffset = headerPageSizeOffset + headerPageSizeSize
+const headerRowSizeSize = 4
+const headerRootPageOffset = headerRowSizeOffset + headerRowSizeSize
+const headerRootPageSize = 4
+const headerNumRowsOffset = headerRootPageOffset + headerRootPageSize
+const headerNumRowsSize = 8
+const headerFeatureFlagsOffset = headerNumRowsOffset + headerNumRowsSize
+const headerFeatureFlagsSize = 8
+const headerVersionedSize = 100
+
+const headerFreeListOffset = headerVersionedSize
+const headerFreeListSize = 4
+const headerNextPageOffset = headerFreeListOffset + headerFreeListSize
+const headerNextPageSize = 4
+const headerCheckpointOffsetOffset = headerNextPageOffset + headerNextPageSize
+const headerCheckpointOffsetSize = 8
+
+// FormatVersion1 is the original fixed-width row layout: every
+// column is padded or truncated to its declared (or default) width.
+// FormatVersion2 stores rows with a self-describing, variable-length
+// encoding instead - see Row.SerializeV2 in sql.go - so a table can
+// hold values that vary widely in size without every row paying for
+// the widest one.
+const (
+	FormatVersion1 = 1
+	FormatVersion2 = 2
+)
+
+const currentFormatVersion = FormatVersion1
+
+/*
+Pager is an abstraction layer for the table.
+It keeps pages cached in memory, and also knows
+how to read and write them from disk. This
+way the table can ask for a given page, and the
+Pager will take care of figuring out whether
+it's already in memory or not.
+*/
+type Pager struct {
+	pageCache    *[tableMaxPages][pageSize]byte
+	cacheIndex   *map[int]bool
+	dirtyPages   *map[int]bool
+	file         *os.File
+	fileReadSize int64
+	wal          *wal
+	txCounter    uint64
+}
+
+/*
+NewPager is the constructor for a pager to take care of allocating
+system resources for a file and memory space for a page cache. A
+brand new (empty) file gets a fresh header written to page 0, using
+defaultFormatVersion; an existing file has its header validated
+(rejecting pre-btree, append-only files instead of silently
+misreading them) and its actual format version read back out of the
+header, regardless of what defaultFormatVersion says.
+*/
+func NewPager(dbFileName string, defaultFormatVersion int) *Pager {
+	if dbFileName == "default" {
+		dbFileName = dbFile
+	}
+	fd, err := os.OpenFile(dbFileName, os.O_RDWR|os.O_CREATE, 0755)
+	if err != nil {
+		log.Fatal("Could not open db file: ", err)
+	}
+	fInfo, err := fd.Stat()
+	if err != nil {
+		log.Fatal("Could not get db file info: ", err)
+	}
+	cIndex := make(map[int]bool)
+	dirty := make(map[int]bool)
+	pager := &Pager{
+		pageCache:    &[tableMaxPages][pageSize]byte{},
+		cacheIndex:   &cIndex,
+		dirtyPages:   &dirty,
+		file:         fd,
+		fileReadSize: fInfo.Size(),
+		wal:          openWAL(walFileName(dbFileName)),
+	}
+	if fInfo.Size() == 0 {
+		pager.initializeHeader(defaultFormatVersion)
+	} else {
+		pager.checkPage(fileHeaderPageNum)
+		if err := pager.validateHeader(); err != nil {
+			log.Fatal("Could not open db file: ", err)
+		}
+		if err := pager.recoverFromWAL(); err != nil {
+			log.Fatal("Could not recover db file from WAL: ", err)
+		}
+	}
+	return pager
+}
+
+/*recoverFromWAL replays any redo records written since the last
+checkpoint into the in-memory page cache, so a crash between a
+commit's WAL fsync and its next checkpoint doesn't lose data. A
+torn tail record (a crash mid-append) is tolerated and simply ends
+replay; a CRC mismatch earlier in the log is real corruption and is
+returned as an ErrCorrupted.*/
+func (p *Pager) recoverFromWAL() error {
+	fromOffset := p.CheckpointOffset()
+	offset, err := replayWAL(p.wal, fromOffset, func(pageNum int, after *[pageSize]byte) {
+		p.pageCache[pageNum] = *after
+		(*p.cacheIndex)[pageNum] = true
+		p.markDirty(pageNum)
+	})
+	if err != nil {
+		return err
+	}
+	if offset > fromOffset {
+		return p.Checkpoint()
+	}
+	return nil
+}
+
+func (p *Pager) headerPage() *[pageSize]byte {
+	return &p.pageCache[fileHeaderPageNum]
+}
+
+func (p *Pager) validateHeader() error {
+	page := p.headerPage()
+	if string(page[0:headerMagicSize]) != headerMagic {
+		return newErrCorrupted("not a charvel db file (bad magic bytes) - may be a pre-btree append-only file")
+	}
+	version := p.FormatVersion()
+	if version != FormatVersion1 && version != FormatVersion2 {
+		return newErrCorrupted(fmt.Sprintf("unsupported charvel db format version %d", version))
+	}
+	return nil
+}
+
+func (p *Pager) initializeHeader(formatVersion int) {
+	page := p.headerPage()
+	copy(page[0:headerMagicSize], []byte(headerMagic))
+	binary.BigEndian.PutUint16(page[headerFormatVersionOffset:headerFormatVersionOffset+headerFormatVersionSize], uint16(formatVersion))
+	binary.BigEndian.PutUint32(page[headerPageSizeOffset:headerPageSizeOffset+headerPageSizeSize], uint32(pageSize))
+	binary.BigEndian.PutUint32(page[headerFreeListOffset:headerFreeListOffset+headerFreeListSize], 0)
+	binary.BigEndian.PutUint32(page[headerNextPageOffset:headerNextPageOffset+headerNextPageSize], firstDataPageNum+1)
+	(*p.cacheIndex)[fileHeaderPageNum] = true
+	p.markDirty(fileHeaderPageNum)
+
+	rootPage := &p.pageCache[firstDataPageNum]
+	setNodeType(rootPage, pageTypeLeaf)
+	setNumCells(rootPage, 0)
+	setParent(rootPage, 0)
+	setRightSibling(rootPage, 0)
+	(*p.cacheIndex)[firstDataPageNum] = true
+	p.markDirty(firstDataPageNum)
+
+	p.SetRootPageNum(firstDataPageNum)
+}
+
+/*
+FormatVersion reads the on-disk format version out of the file
+header - FormatVersion1 (fixed-width rows) or FormatVersion2
+(variable-length rows, see Row.SerializeV2)
+*/
+func (p *Pager) FormatVersion() int {
+	page := p.headerPage()
+	return int(binary.BigEndian.Uint16(page[headerFormatVersionOffset : headerFormatVersionOffset+headerFormatVersionSize]))
+}
+
+/*
+RowSize reports the fixed row width a FormatVersion1 table was
+created with, for self-description; FormatVersion2 tables don't
+have a single row size, so they leave this at 0
+*/
+func (p *Pager) RowSize() int {
+	page := p.headerPage()
+	return int(binary.BigEndian.Uint32(page[headerRowSizeOffset : headerRowSizeOffset+headerRowSizeSize]))
+}
+
+/*SetRowSize persists schema.RowSize() into the header, once, right after a FormatVersion1 table is created*/
+func (p *Pager) SetRowSize(rowSize int) {
+	page := p.headerPage()
+	binary.BigEndian.PutUint32(page[headerRowSizeOffset:headerRowSizeOffset+headerRowSizeSize], uint32(rowSize))
+	p.markDirty(fileHeaderPageNum)
+}
+
+/*RowCount returns the number of rows NewPager's header has recorded via IncrementRowCount*/
+func (p *Pager) RowCount() int {
+	page := p.headerPage()
+	return int(binary.BigEndian.Uint64(page[headerNumRowsOffset : headerNumRowsOffset+headerNumRowsSize]))
+}
+
+/*IncrementRowCount bumps the header's persisted row count by one, called once per successful Insert*/
+func (p *Pager) IncrementRowCount() {
+	page := p.headerPage()
+	count := binary.BigEndian.Uint64(page[headerNumRowsOffset : headerNumRowsOffset+headerNumRowsSize])
+	binary.BigEndian.PutUint64(page[headerNumRowsOffset:headerNumRowsOffset+headerNumRowsSize], count+1)
+	p.markDirty(fileHeaderPageNum)
+}
+
+/*RootPageNum reads the current root page number out of the file header*/
+func (p *Pager) RootPageNum() int {
+	page := p.headerPage()
+	return int(binary.BigEndian.Uint32(page[headerRootPageOffset : headerRootPageOffset+headerRootPageSize]))
+}
+
+/*
+SetRootPageNum rewrites the file header's root page pointer,
+used whenever a split promotes a new root
+*/
+func (p *Pager) SetRootPageNum(pageNum int) {
+	setRootPageNumInPage(p.headerPage(), pageNum)
+	p.markDirty(fileHeaderPageNum)
+}
+
+/*setRootPageNumInPage writes the root pointer into a header page
+image without touching the pager's live cache - Tx.Commit uses this
+to build the header's "after" image for its own WAL record instead
+of mutating the pager directly ahead of that record being durable.*/
+func setRootPageNumInPage(page *[pageSize]byte, pageNum int) {
+	binary.BigEndian.PutUint32(page[headerRootPageOffset:headerRootPageOffset+headerRootPageSize], uint32(pageNum))
+}
+
+/*pageCount returns one past the highest page number ever handed out
+by AllocatePage - the bound a Tx snapshotting every existing page
+needs, since pages at or beyond it don't exist yet.*/
+func (p *Pager) pageCount() int {
+	page := p.headerPage()
+	return int(binary.BigEndian.Uint32(page[headerNextPageOffset : headerNextPageOffset+headerNextPageSize]))
+}
+
+/*
+AllocatePage hands out the next unused page number and persists
+the bump in the header so it survives a close/reopen. There is no
+free list reuse yet - the free list head is reserved in the header
+for a future release that recycles pages freed by deletes/merges.
+*/
+func (p *Pager) AllocatePage() int {
+	page := p.headerPage()
+	next := int(binary.BigEndian.Uint32(page[headerNextPageOffset : headerNextPageOffset+headerNextPageSize]))
+	binary.BigEndian.PutUint32(page[headerNextPageOffset:headerNextPageOffset+headerNextPageSize], uint32(next+1))
+	p.markDirty(fileHeaderPageNum)
+	return next
+}
+
+func (p *Pager) markDirty(pageNum int) {
+	(*p.dirtyPages)[pageNum] = true
+}
+
+/*CheckpointOffset returns the WAL byte offset up to which every
+record has already been applied to the main db file*/
+func (p *Pager) CheckpointOffset() int64 {
+	page := p.headerPage()
+	return int64(binary.BigEndian.Uint64(page[headerCheckpointOffsetOffset : headerCheckpointOffsetOffset+headerCheckpointOffsetSize]))
+}
+
+func (p *Pager) setCheckpointOffset(offset int64) {
+	page := p.headerPage()
+	binary.BigEndian.PutUint64(page[headerCheckpointOffsetOffset:headerCheckpointOffsetOffset+headerCheckpointOffsetSize], uint64(offset))
+	p.markDirty(fileHeaderPageNum)
+}
+
+/*nextTxID hands out a process-local, monotonically increasing
+transaction identifier for WAL records. It isn't persisted - on
+restart it simply starts back at 1, which is harmless since WAL
+records are ordered by lsn (their own byte offset), not by txID.*/
+func (p *Pager) nextTxID() uint64 {
+	p.txCounter++
+	return p.txCounter
+}
+
+/*Checkpoint flushes every page dirtied since the last checkpoint
+to the main db file and advances the header's checkpoint offset
+past the WAL records that covered them, so a future open doesn't
+need to replay what's already durable on disk. In a long-running
+process this would run on a timer in the background; here it runs
+synchronously on Close and whenever recovery replays anything.*/
+func (p *Pager) Checkpoint() error {
+	for pageNum := range *p.dirtyPages {
+		p.Flush(pageNum)
+	}
+	*p.dirtyPages = make(map[int]bool)
+	if err := p.file.Sync(); err != nil {
+		return err
+	}
+
+	endOffset, err := p.wal.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	p.setCheckpointOffset(endOffset)
+	p.Flush(fileHeaderPageNum)
+	return p.file.Sync()
+}
+
+/*
+GetPage ensures the requested page is cached in memory and
+returns a pointer to it so callers can read or mutate it in place
+*/
+func (p *Pager) GetPage(pageNum int) *[pageSize]byte {
+	p.checkPage(pageNum)
+	return &p.pageCache[pageNum]
+}
+
+/*Flush writes one page to disk in its entirety*/
+func (p *Pager) Flush(pageNum int) {
+	pageOffset := int64(pageNum * pageSize)
+	p.file.Seek(pageOffset, 0)
+	p.file.Write(p.pageCache[pageNum][:])
+}
+
+/*
+Close checkpoints every page dirtied since the last checkpoint to
+disk, then closes the WAL and the underlying db file so nothing is
+left un-flushed
+*/
+func (p *Pager) Close() {
+	if err := p.Checkpoint(); err != nil {
+		log.Println("Failed to checkpoint on close: ", err)
+	}
+	p.wal.close()
+	p.file.Close()
+}
+
+func (p *Pager) cachePage(pageNum int) {
+	p.file.Seek(int64(pageNum*pageSize), 0)
+	pageBuffer := make([]byte, pageSize)
+	_, err := p.file.Read(pageBuffer)
+	if err != nil && err != io.EOF {
+		log.Fatal("Failed to cache page ", err)
+	}
+	for i, byteVal := range pageBuffer {
+		p.pageCache[pageNum][i] = byteVal
+	}
+}
+
+func (p *Pager) checkPage(pageNum int) {
+	loaded, ok := (*p.cacheIndex)[pageNum]
+	if !ok || !loaded {
+		p.cachePage(pageNum)
+		(*p.cacheIndex)[pageNum] = true
+	}
+}
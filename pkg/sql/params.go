@@ -0,0 +1,224 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+paramSlot describes one parameter a prepared Statement expects:
+its target column's type, for Bind/BindNamed's type-checking, and
+(if the command used :name syntax) the name BindNamed looks it up by.
+*/
+type paramSlot struct {
+	name    string
+	colType ColumnType
+}
+
+func (p paramSlot) label(pos int) string {
+	if p.name != "" {
+		return ":" + p.name
+	}
+	return fmt.Sprintf("parameter %d", pos+1)
+}
+
+/*
+collectParams walks a parsed Stmt and returns its parameter slots,
+in placeholder order, resolving each one's expected type against the
+schema of the table the statement targets
+*/
+func (e *Engine) collectParams(stmt Stmt) ([]paramSlot, error) {
+	var tableName string
+	var where *WhereClause
+	switch st := stmt.(type) {
+	case *InsertStmt:
+		tableName = st.Table
+	case *SelectStmt:
+		tableName, where = st.Table, st.Where
+	case *UpdateStmt:
+		tableName, where = st.Table, st.Where
+	case *DeleteStmt:
+		tableName, where = st.Table, st.Where
+	default:
+		return nil, nil
+	}
+
+	table, ok := e.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("no such table: %s", tableName)
+	}
+	schema := table.Schema()
+
+	slots := make([]paramSlot, countPlaceholders(stmt))
+	if insert, ok := stmt.(*InsertStmt); ok {
+		for i, v := range insert.Values {
+			if ph, ok := v.(Placeholder); ok {
+				slots[ph.Pos] = paramSlot{name: ph.Name, colType: schema.Columns[i].Type}
+			}
+		}
+	}
+	fillWhereParams(where, slots)
+	return slots, nil
+}
+
+func countPlaceholders(stmt Stmt) int {
+	switch st := stmt.(type) {
+	case *InsertStmt:
+		n := 0
+		for _, v := range st.Values {
+			if _, ok := v.(Placeholder); ok {
+				n++
+			}
+		}
+		return n
+	case *SelectStmt:
+		return countWhereParams(st.Where)
+	case *UpdateStmt:
+		return countWhereParams(st.Where)
+	case *DeleteStmt:
+		return countWhereParams(st.Where)
+	default:
+		return 0
+	}
+}
+
+func countWhereParams(w *WhereClause) int {
+	if w == nil {
+		return 0
+	}
+	n := 0
+	if w.ValueParam != nil {
+		n++
+	}
+	if w.HighParam != nil {
+		n++
+	}
+	return n
+}
+
+func fillWhereParams(w *WhereClause, slots []paramSlot) {
+	if w == nil {
+		return
+	}
+	if w.ValueParam != nil {
+		slots[w.ValueParam.Pos] = paramSlot{name: w.ValueParam.Name, colType: ColumnInt32}
+	}
+	if w.HighParam != nil {
+		slots[w.HighParam.Pos] = paramSlot{name: w.HighParam.Name, colType: ColumnInt32}
+	}
+}
+
+func checkParamType(slot paramSlot, pos int, arg interface{}) error {
+	switch slot.colType {
+	case ColumnInt32:
+		if _, ok := arg.(int32); !ok {
+			return fmt.Errorf("%s expects an INT32 value", slot.label(pos))
+		}
+	default:
+		if _, ok := arg.(string); !ok {
+			return fmt.Errorf("%s expects a string value", slot.label(pos))
+		}
+	}
+	return nil
+}
+
+/*
+ErrStatementNotBound is returned by ExecutePrepared's resolve step
+when a statement has parameters but Bind/BindNamed was never called
+*/
+var ErrStatementNotBound = errors.New("statement has unbound parameters; call Bind or BindNamed first")
+
+/*
+Bind type-checks args against this statement's parameter slots, in
+positional order, and stores them for the next Execute/ExecutePrepared.
+Calling Bind again (to reuse the same prepared Statement with new
+values) replaces whatever was bound before.
+*/
+func (s *Statement) Bind(args ...interface{}) error {
+	if len(args) != len(s.params) {
+		return fmt.Errorf("statement expects %d parameter(s), got %d", len(s.params), len(args))
+	}
+	bound := make([]interface{}, len(args))
+	for i, arg := range args {
+		if err := checkParamType(s.params[i], i, arg); err != nil {
+			return err
+		}
+		bound[i] = arg
+	}
+	s.bound = bound
+	return nil
+}
+
+/*
+BindNamed is Bind for :name-style placeholders, looking each
+parameter's value up by name instead of position
+*/
+func (s *Statement) BindNamed(args map[string]interface{}) error {
+	bound := make([]interface{}, len(s.params))
+	for i, slot := range s.params {
+		if slot.name == "" {
+			return fmt.Errorf("parameter %d is positional; use Bind instead", i+1)
+		}
+		arg, ok := args[slot.name]
+		if !ok {
+			return fmt.Errorf("missing value for parameter :%s", slot.name)
+		}
+		if err := checkParamType(slot, i, arg); err != nil {
+			return err
+		}
+		bound[i] = arg
+	}
+	s.bound = bound
+	return nil
+}
+
+/*
+resolved substitutes this statement's bound values for every
+Placeholder in its AST, returning a Stmt ready to execute. A
+statement with no parameters resolves even if Bind was never called.
+*/
+func (s *Statement) resolved() (Stmt, error) {
+	if len(s.params) > 0 && s.bound == nil {
+		return nil, ErrStatementNotBound
+	}
+	return resolveStmt(s.stmt, s.bound), nil
+}
+
+func resolveStmt(stmt Stmt, bound []interface{}) Stmt {
+	switch st := stmt.(type) {
+	case *InsertStmt:
+		values := make([]interface{}, len(st.Values))
+		for i, v := range st.Values {
+			if ph, ok := v.(Placeholder); ok {
+				values[i] = bound[ph.Pos]
+			} else {
+				values[i] = v
+			}
+		}
+		return &InsertStmt{Table: st.Table, Values: values}
+	case *SelectStmt:
+		return &SelectStmt{Table: st.Table, Where: resolveWhere(st.Where, bound)}
+	case *UpdateStmt:
+		return &UpdateStmt{Table: st.Table, Where: resolveWhere(st.Where, bound)}
+	case *DeleteStmt:
+		return &DeleteStmt{Table: st.Table, Where: resolveWhere(st.Where, bound)}
+	default:
+		return stmt
+	}
+}
+
+func resolveWhere(w *WhereClause, bound []interface{}) *WhereClause {
+	if w == nil {
+		return nil
+	}
+	resolved := *w
+	if w.ValueParam != nil {
+		resolved.Value = bound[w.ValueParam.Pos].(int32)
+		resolved.ValueParam = nil
+	}
+	if w.HighParam != nil {
+		resolved.High = bound[w.HighParam.Pos].(int32)
+		resolved.HighParam = nil
+	}
+	return &resolved
+}
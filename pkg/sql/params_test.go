@@ -0,0 +1,149 @@
+package sql
+
+import "testing"
+
+func newAccountsEngine(t *testing.T) *Engine {
+	engine := newCatalogEngine(t)
+	schema := &Schema{
+		TableName: "accounts",
+		Columns: []Column{
+			{Name: "id", Type: ColumnInt32, PrimaryKey: true},
+			{Name: "name", Type: ColumnVarchar, Size: 32},
+		},
+	}
+	if err := engine.CreateTable(schema); err != nil {
+		t.Fatal("Failed to create accounts table", err)
+	}
+	return engine
+}
+
+func TestPreparedInsertReusedAcrossManyBindings(t *testing.T) {
+	engine := newAccountsEngine(t)
+	statement, err := engine.Prepare("INSERT INTO accounts VALUES (?, ?)")
+	if err != nil {
+		t.Fatal("Failed to prepare parameterized INSERT", err)
+	}
+
+	rows := []struct {
+		id   int32
+		name string
+	}{
+		{1, "alice"},
+		{2, "bob"},
+		{3, "carol"},
+	}
+	for _, row := range rows {
+		if err := engine.ExecutePrepared(statement, row.id, row.name); err != nil {
+			t.Fatalf("Failed to execute prepared INSERT for %v: %v", row, err)
+		}
+	}
+
+	for _, row := range rows {
+		if err := engine.View("accounts", func(tx *Tx) error {
+			fetched, err := tx.FetchRow(row.id)
+			if err != nil {
+				return err
+			}
+			if fetched.Values[1].(string) != row.name {
+				t.Errorf("Expected row %d to have name %s, got %v", row.id, row.name, fetched.Values[1])
+			}
+			return nil
+		}); err != nil {
+			t.Error("Failed to fetch inserted row", err)
+		}
+	}
+}
+
+func TestPreparedInsertNamedPlaceholders(t *testing.T) {
+	engine := newAccountsEngine(t)
+	statement, err := engine.Prepare("INSERT INTO accounts VALUES (:id, :name)")
+	if err != nil {
+		t.Fatal("Failed to prepare named-parameter INSERT", err)
+	}
+	if err := statement.BindNamed(map[string]interface{}{"id": int32(7), "name": "dana"}); err != nil {
+		t.Fatal("Failed to bind named parameters", err)
+	}
+	if err := engine.ExecutePrepared(statement); err != nil {
+		t.Fatal("Failed to execute with named-bound values", err)
+	}
+	if err := engine.View("accounts", func(tx *Tx) error {
+		row, err := tx.FetchRow(7)
+		if err != nil {
+			return err
+		}
+		if row.Values[1].(string) != "dana" {
+			t.Error("Unexpected row contents", row)
+		}
+		return nil
+	}); err != nil {
+		t.Error("Failed to fetch row bound by name", err)
+	}
+}
+
+func TestBindRejectsTypeMismatch(t *testing.T) {
+	engine := newAccountsEngine(t)
+	statement, err := engine.Prepare("INSERT INTO accounts VALUES (?, ?)")
+	if err != nil {
+		t.Fatal("Failed to prepare INSERT", err)
+	}
+	if err := statement.Bind("not-an-int32", "alice"); err == nil {
+		t.Error("Expected Bind to reject a string in an INT32 slot")
+	}
+	if err := statement.Bind(int32(1), int32(2)); err == nil {
+		t.Error("Expected Bind to reject an int32 in a VARCHAR slot")
+	}
+}
+
+func TestBindRejectsWrongArgCount(t *testing.T) {
+	engine := newAccountsEngine(t)
+	statement, err := engine.Prepare("INSERT INTO accounts VALUES (?, ?)")
+	if err != nil {
+		t.Fatal("Failed to prepare INSERT", err)
+	}
+	if err := statement.Bind(int32(1)); err == nil {
+		t.Error("Expected Bind to reject too few arguments")
+	}
+}
+
+func TestBindNamedRejectsMissingKey(t *testing.T) {
+	engine := newAccountsEngine(t)
+	statement, err := engine.Prepare("INSERT INTO accounts VALUES (:id, :name)")
+	if err != nil {
+		t.Fatal("Failed to prepare named-parameter INSERT", err)
+	}
+	if err := statement.BindNamed(map[string]interface{}{"id": int32(1)}); err == nil {
+		t.Error("Expected BindNamed to reject a missing :name key")
+	}
+}
+
+func TestUnboundStatementRejectsResolve(t *testing.T) {
+	engine := newAccountsEngine(t)
+	statement, err := engine.Prepare("INSERT INTO accounts VALUES (?, ?)")
+	if err != nil {
+		t.Fatal("Failed to prepare INSERT", err)
+	}
+	if _, err := statement.resolved(); err == nil {
+		t.Error("Expected resolved() to reject a statement with unbound parameters")
+	}
+}
+
+func TestPreparedSelectWhereEqualsPlaceholder(t *testing.T) {
+	engine := newAccountsEngine(t)
+	if err := engine.Update("accounts", func(tx *Tx) error {
+		row, err := NewRowWithSchema(tx.Schema(), int32(42), "zed")
+		if err != nil {
+			return err
+		}
+		return tx.Insert(row)
+	}); err != nil {
+		t.Fatal("Failed to seed row", err)
+	}
+
+	statement, err := engine.Prepare("SELECT * FROM accounts WHERE id = ?")
+	if err != nil {
+		t.Fatal("Failed to prepare parameterized SELECT", err)
+	}
+	if err := engine.ExecutePrepared(statement, int32(42)); err != nil {
+		t.Fatal("Failed to execute parameterized SELECT", err)
+	}
+}
@@ -0,0 +1,484 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokNumber
+	tokString
+	tokPunct
+	tokParam
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+/*
+tokenize splits a raw command into idents, numbers, quoted
+strings, :name parameters, and the handful of punctuation characters
+this grammar needs: ( ) , ; * = ?
+*/
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+	i := 0
+	for i < len(runes) {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),;*=?", c):
+			tokens = append(tokens, token{kind: tokPunct, text: string(c)})
+			i++
+		case c == ':':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			if j == i+1 {
+				return nil, fmt.Errorf("expected a parameter name after ':' in: %s", input)
+			}
+			tokens = append(tokens, token{kind: tokParam, text: string(runes[i+1 : j])})
+			i = j
+		case c == '\'' || c == '"':
+			quote := c
+			j := i + 1
+			for j < len(runes) && runes[j] != quote {
+				j++
+			}
+			if j >= len(runes) {
+				return nil, fmt.Errorf("unterminated string literal in: %s", input)
+			}
+			tokens = append(tokens, token{kind: tokString, text: string(runes[i+1 : j])})
+			i = j + 1
+		case c == '-' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: string(runes[i:j])})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i + 1
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j]) || runes[j] == '_') {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokIdent, text: string(runes[i:j])})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character %q in: %s", c, input)
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens   []token
+	pos      int
+	paramPos int
+}
+
+/*
+nextPlaceholder allocates the next sequential Placeholder slot, in
+the order placeholders appear in the command - this is also the
+index Statement.Bind/BindNamed store their resolved values under.
+*/
+func (p *parser) nextPlaceholder(name string) Placeholder {
+	ph := Placeholder{Name: name, Pos: p.paramPos}
+	p.paramPos++
+	return ph
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) next() (token, bool) {
+	tok, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return tok, ok
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	tok, ok := p.next()
+	if !ok || tok.kind != tokIdent || !strings.EqualFold(tok.text, kw) {
+		return fmt.Errorf("expected %q", kw)
+	}
+	return nil
+}
+
+func (p *parser) expectPunct(text string) error {
+	tok, ok := p.next()
+	if !ok || tok.kind != tokPunct || tok.text != text {
+		return fmt.Errorf("expected %q", text)
+	}
+	return nil
+}
+
+func (p *parser) expectIdent() (string, error) {
+	tok, ok := p.next()
+	if !ok || tok.kind != tokIdent {
+		return "", errors.New("expected an identifier")
+	}
+	return tok.text, nil
+}
+
+func (p *parser) expectNumber() (int32, error) {
+	tok, ok := p.next()
+	if !ok || tok.kind != tokNumber {
+		return 0, errors.New("expected a number")
+	}
+	n, err := strconv.Atoi(tok.text)
+	if err != nil {
+		return 0, err
+	}
+	return int32(n), nil
+}
+
+/*
+expectNumberOrParam reads a literal number, or a `?`/`:name`
+placeholder in its place, returning whichever one it saw
+*/
+func (p *parser) expectNumberOrParam() (int32, *Placeholder, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return 0, nil, errors.New("expected a number or parameter")
+	}
+	if tok.kind == tokPunct && tok.text == "?" {
+		p.next()
+		ph := p.nextPlaceholder("")
+		return 0, &ph, nil
+	}
+	if tok.kind == tokParam {
+		p.next()
+		ph := p.nextPlaceholder(tok.text)
+		return 0, &ph, nil
+	}
+	n, err := p.expectNumber()
+	return n, nil, err
+}
+
+func (p *parser) consumeOptionalSemicolon() {
+	if tok, ok := p.peek(); ok && tok.kind == tokPunct && tok.text == ";" {
+		p.next()
+	}
+}
+
+/*
+Parse tokenizes and parses a single SQL command into its AST,
+dispatching on the leading keyword
+*/
+func Parse(command string) (Stmt, error) {
+	tokens, err := tokenize(command)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("empty statement")
+	}
+	p := &parser{tokens: tokens}
+	kw, _ := p.peek()
+	if kw.kind != tokIdent {
+		return nil, fmt.Errorf("unrecognized keyword at start of statement: %s", command)
+	}
+	switch strings.ToUpper(kw.text) {
+	case "CREATE":
+		return p.parseCreateTable()
+	case "INSERT":
+		return p.parseInsert()
+	case "SELECT":
+		return p.parseSelect()
+	case "UPDATE":
+		return p.parseUpdate()
+	case "DELETE":
+		return p.parseDelete()
+	default:
+		return nil, fmt.Errorf("unrecognized keyword at start of statement: %s", kw.text)
+	}
+}
+
+func (p *parser) parseCreateTable() (Stmt, error) {
+	if err := p.expectKeyword("CREATE"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+
+	var columns []Column
+	for {
+		col, err := p.parseColumnDef()
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, col)
+		tok, ok := p.peek()
+		if !ok {
+			return nil, errors.New("unterminated column list")
+		}
+		if tok.kind == tokPunct && tok.text == "," {
+			p.next()
+			continue
+		}
+		break
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	p.consumeOptionalSemicolon()
+	return &CreateTableStmt{Schema: &Schema{TableName: tableName, Columns: columns}}, nil
+}
+
+func (p *parser) parseColumnDef() (Column, error) {
+	name, err := p.expectIdent()
+	if err != nil {
+		return Column{}, err
+	}
+	typeTok, err := p.expectIdent()
+	if err != nil {
+		return Column{}, err
+	}
+
+	col := Column{Name: name, Nullable: true}
+	switch strings.ToUpper(typeTok) {
+	case "INT32":
+		col.Type = ColumnInt32
+	case "VARCHAR":
+		if err := p.expectPunct("("); err != nil {
+			return Column{}, err
+		}
+		size, err := p.expectNumber()
+		if err != nil {
+			return Column{}, errors.New("expected a size for VARCHAR(n)")
+		}
+		if size <= 0 {
+			return Column{}, fmt.Errorf("VARCHAR size must be positive, got %d", size)
+		}
+		col.Type = ColumnVarchar
+		col.Size = int(size)
+		if err := p.expectPunct(")"); err != nil {
+			return Column{}, err
+		}
+	case "TEXT":
+		col.Type = ColumnText
+	default:
+		return Column{}, fmt.Errorf("unknown column type: %s", typeTok)
+	}
+
+modifiers:
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokIdent {
+			break modifiers
+		}
+		switch strings.ToUpper(tok.text) {
+		case "PRIMARY":
+			p.next()
+			if err := p.expectKeyword("KEY"); err != nil {
+				return Column{}, err
+			}
+			col.PrimaryKey = true
+			col.Nullable = false
+		case "NOT":
+			p.next()
+			if err := p.expectKeyword("NULL"); err != nil {
+				return Column{}, err
+			}
+			col.Nullable = false
+		default:
+			break modifiers
+		}
+	}
+	return col, nil
+}
+
+func (p *parser) parseInsert() (Stmt, error) {
+	if err := p.expectKeyword("INSERT"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("INTO"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("VALUES"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("("); err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	for {
+		tok, ok := p.next()
+		if !ok {
+			return nil, errors.New("unterminated value list")
+		}
+		switch {
+		case tok.kind == tokNumber:
+			n, err := strconv.Atoi(tok.text)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, int32(n))
+		case tok.kind == tokString:
+			values = append(values, tok.text)
+		case tok.kind == tokPunct && tok.text == "?":
+			values = append(values, p.nextPlaceholder(""))
+		case tok.kind == tokParam:
+			values = append(values, p.nextPlaceholder(tok.text))
+		default:
+			return nil, fmt.Errorf("unexpected token in VALUES list: %s", tok.text)
+		}
+		next, ok := p.peek()
+		if !ok {
+			return nil, errors.New("unterminated value list")
+		}
+		if next.kind == tokPunct && next.text == "," {
+			p.next()
+			continue
+		}
+		break
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return nil, err
+	}
+	p.consumeOptionalSemicolon()
+	return &InsertStmt{Table: tableName, Values: values}, nil
+}
+
+func (p *parser) parseSelect() (Stmt, error) {
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if err := p.expectPunct("*"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := &SelectStmt{Table: tableName}
+	if tok, ok := p.peek(); ok && tok.kind == tokIdent && strings.EqualFold(tok.text, "WHERE") {
+		where, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Where = where
+	}
+	p.consumeOptionalSemicolon()
+	return stmt, nil
+}
+
+func (p *parser) parseUpdate() (Stmt, error) {
+	if err := p.expectKeyword("UPDATE"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &UpdateStmt{Table: tableName}
+	if tok, ok := p.peek(); ok && tok.kind == tokIdent && strings.EqualFold(tok.text, "WHERE") {
+		where, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Where = where
+	}
+	p.consumeOptionalSemicolon()
+	return stmt, nil
+}
+
+func (p *parser) parseDelete() (Stmt, error) {
+	if err := p.expectKeyword("DELETE"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return nil, err
+	}
+	tableName, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	stmt := &DeleteStmt{Table: tableName}
+	if tok, ok := p.peek(); ok && tok.kind == tokIdent && strings.EqualFold(tok.text, "WHERE") {
+		where, err := p.parseWhere()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Where = where
+	}
+	p.consumeOptionalSemicolon()
+	return stmt, nil
+}
+
+func (p *parser) parseWhere() (*WhereClause, error) {
+	if err := p.expectKeyword("WHERE"); err != nil {
+		return nil, err
+	}
+	column, err := p.expectIdent()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(column, "id") {
+		return nil, fmt.Errorf("WHERE is only supported on the id column, got %s", column)
+	}
+
+	tok, ok := p.peek()
+	if !ok {
+		return nil, errors.New("expected = or BETWEEN after WHERE id")
+	}
+	if tok.kind == tokPunct && tok.text == "=" {
+		p.next()
+		value, valueParam, err := p.expectNumberOrParam()
+		if err != nil {
+			return nil, err
+		}
+		return &WhereClause{Column: column, Op: whereOpEquals, Value: value, ValueParam: valueParam}, nil
+	}
+	if tok.kind == tokIdent && strings.EqualFold(tok.text, "BETWEEN") {
+		p.next()
+		low, lowParam, err := p.expectNumberOrParam()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		high, highParam, err := p.expectNumberOrParam()
+		if err != nil {
+			return nil, err
+		}
+		return &WhereClause{Column: column, Op: whereOpBetween, Value: low, High: high, ValueParam: lowParam, HighParam: highParam}, nil
+	}
+	return nil, errors.New("expected = or BETWEEN after WHERE id")
+}
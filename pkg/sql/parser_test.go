@@ -0,0 +1,138 @@
+package sql
+
+import "testing"
+
+func TestParseCreateTable(t *testing.T) {
+	stmt, err := Parse("CREATE TABLE accounts (id INT32 PRIMARY KEY, name VARCHAR(64), bio TEXT)")
+	if err != nil {
+		t.Fatal("Failed to parse CREATE TABLE", err)
+	}
+	create, ok := stmt.(*CreateTableStmt)
+	if !ok {
+		t.Fatalf("Expected *CreateTableStmt, got %T", stmt)
+	}
+	if create.Schema.TableName != "accounts" {
+		t.Error("Expected table name 'accounts', got ", create.Schema.TableName)
+	}
+	if len(create.Schema.Columns) != 3 {
+		t.Fatal("Expected 3 columns, got ", len(create.Schema.Columns))
+	}
+	if idx, err := create.Schema.PrimaryKeyIndex(); err != nil || idx != 0 {
+		t.Error("Expected column 0 to be the primary key", idx, err)
+	}
+	if create.Schema.Columns[1].Type != ColumnVarchar || create.Schema.Columns[1].Size != 64 {
+		t.Error("Expected name to be VARCHAR(64), got ", create.Schema.Columns[1])
+	}
+	if create.Schema.Columns[2].Type != ColumnText {
+		t.Error("Expected bio to be TEXT, got ", create.Schema.Columns[2])
+	}
+}
+
+func TestParseInsert(t *testing.T) {
+	stmt, err := Parse(`INSERT INTO accounts VALUES (1, "alice", 'bio text')`)
+	if err != nil {
+		t.Fatal("Failed to parse INSERT", err)
+	}
+	insert, ok := stmt.(*InsertStmt)
+	if !ok {
+		t.Fatalf("Expected *InsertStmt, got %T", stmt)
+	}
+	if insert.Table != "accounts" {
+		t.Error("Expected table 'accounts', got ", insert.Table)
+	}
+	if len(insert.Values) != 3 || insert.Values[0] != int32(1) || insert.Values[1] != "alice" {
+		t.Error("Unexpected parsed values: ", insert.Values)
+	}
+}
+
+func TestParseSelectWithEquals(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM accounts WHERE id = 5")
+	if err != nil {
+		t.Fatal("Failed to parse SELECT", err)
+	}
+	sel, ok := stmt.(*SelectStmt)
+	if !ok {
+		t.Fatalf("Expected *SelectStmt, got %T", stmt)
+	}
+	if sel.Where == nil || sel.Where.Op != whereOpEquals || sel.Where.Value != 5 {
+		t.Error("Expected WHERE id = 5, got ", sel.Where)
+	}
+}
+
+func TestParseSelectWithBetween(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM accounts WHERE id BETWEEN 1 AND 10")
+	if err != nil {
+		t.Fatal("Failed to parse SELECT", err)
+	}
+	sel := stmt.(*SelectStmt)
+	if sel.Where == nil || sel.Where.Op != whereOpBetween || sel.Where.Value != 1 || sel.Where.High != 10 {
+		t.Error("Expected WHERE id BETWEEN 1 AND 10, got ", sel.Where)
+	}
+}
+
+func TestParseInsertWithPositionalPlaceholders(t *testing.T) {
+	stmt, err := Parse("INSERT INTO accounts VALUES (?, ?)")
+	if err != nil {
+		t.Fatal("Failed to parse parameterized INSERT", err)
+	}
+	insert := stmt.(*InsertStmt)
+	if len(insert.Values) != 2 {
+		t.Fatal("Expected 2 values, got ", len(insert.Values))
+	}
+	first, ok := insert.Values[0].(Placeholder)
+	if !ok || first.Pos != 0 {
+		t.Error("Expected first value to be Placeholder{Pos: 0}, got ", insert.Values[0])
+	}
+	second, ok := insert.Values[1].(Placeholder)
+	if !ok || second.Pos != 1 {
+		t.Error("Expected second value to be Placeholder{Pos: 1}, got ", insert.Values[1])
+	}
+}
+
+func TestParseInsertWithNamedPlaceholders(t *testing.T) {
+	stmt, err := Parse("INSERT INTO accounts VALUES (:id, :name)")
+	if err != nil {
+		t.Fatal("Failed to parse named-parameter INSERT", err)
+	}
+	insert := stmt.(*InsertStmt)
+	id, ok := insert.Values[0].(Placeholder)
+	if !ok || id.Name != "id" {
+		t.Error("Expected first value to be Placeholder{Name: \"id\"}, got ", insert.Values[0])
+	}
+	name, ok := insert.Values[1].(Placeholder)
+	if !ok || name.Name != "name" {
+		t.Error("Expected second value to be Placeholder{Name: \"name\"}, got ", insert.Values[1])
+	}
+}
+
+func TestParseSelectWhereEqualsPlaceholder(t *testing.T) {
+	stmt, err := Parse("SELECT * FROM accounts WHERE id = ?")
+	if err != nil {
+		t.Fatal("Failed to parse parameterized SELECT", err)
+	}
+	sel := stmt.(*SelectStmt)
+	if sel.Where == nil || sel.Where.ValueParam == nil || sel.Where.ValueParam.Pos != 0 {
+		t.Error("Expected WHERE id = <placeholder 0>, got ", sel.Where)
+	}
+}
+
+func TestParseRejectsUnrecognizedKeyword(t *testing.T) {
+	if _, err := Parse("frobnicate accounts"); err == nil {
+		t.Error("Expected an error for an unrecognized statement")
+	}
+}
+
+func TestParseRejectsWhereOnNonIDColumn(t *testing.T) {
+	if _, err := Parse("SELECT * FROM accounts WHERE name = 5"); err == nil {
+		t.Error("Expected an error for a WHERE clause on a non-id column")
+	}
+}
+
+func TestParseRejectsNonPositiveVarcharSize(t *testing.T) {
+	if _, err := Parse("CREATE TABLE accounts (id INT32 PRIMARY KEY, label VARCHAR(-5))"); err == nil {
+		t.Error("Expected an error for a negative VARCHAR size")
+	}
+	if _, err := Parse("CREATE TABLE accounts (id INT32 PRIMARY KEY, label VARCHAR(0))"); err == nil {
+		t.Error("Expected an error for a zero VARCHAR size")
+	}
+}
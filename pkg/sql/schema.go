@@ -0,0 +1,188 @@
+package sql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+/*
+ColumnType enumerates the column types a Schema can describe. A
+FormatVersion1 table lays every row out at a fixed width, so
+VARCHAR(n) and TEXT both reserve a fixed byte slot - TEXT just
+defaults that slot to defaultTextSize when the DDL doesn't say
+otherwise. A FormatVersion2 table stores each value at its own
+length instead (see Row.SerializeV2).
+*/
+type ColumnType uint8
+
+const (
+	ColumnInt32 ColumnType = iota
+	ColumnVarchar
+	ColumnText
+)
+
+const defaultTextSize = 255
+
+/*Column describes one field of a table's Schema*/
+type Column struct {
+	Name       string
+	Type       ColumnType
+	Size       int
+	Nullable   bool
+	PrimaryKey bool
+}
+
+func (c Column) width() int {
+	switch c.Type {
+	case ColumnInt32:
+		return idSize
+	case ColumnText:
+		if c.Size > 0 {
+			return c.Size
+		}
+		return defaultTextSize
+	default:
+		return c.Size
+	}
+}
+
+/*
+ErrNoPrimaryKey is returned when a Schema is asked for its primary
+key column but none of its columns were marked PrimaryKey
+*/
+var ErrNoPrimaryKey = errors.New("schema has no primary key column")
+
+/*
+Schema describes a table's columns, in storage order. The primary
+key column is always an int32 - it doubles as the B+Tree's key, so
+WHERE id = N and WHERE id BETWEEN a AND b can use the tree directly.
+*/
+type Schema struct {
+	TableName string
+	Columns   []Column
+}
+
+/*
+PrimaryKeyIndex returns the position of the primary key column in
+Columns, or ErrNoPrimaryKey if the schema doesn't have one
+*/
+func (s *Schema) PrimaryKeyIndex() (int, error) {
+	for i, col := range s.Columns {
+		if col.PrimaryKey {
+			return i, nil
+		}
+	}
+	return 0, ErrNoPrimaryKey
+}
+
+/*
+RowSize returns the fixed byte width a Row matching this schema
+occupies once serialized
+*/
+func (s *Schema) RowSize() int {
+	size := 0
+	for _, col := range s.Columns {
+		size += col.width()
+	}
+	return size
+}
+
+/*
+DefaultUsersSchema is the schema of the original hardcoded users
+table (id/username/email), kept around as the shape NewRow and the
+pre-catalog tests build rows against.
+*/
+func DefaultUsersSchema() *Schema {
+	return &Schema{
+		TableName: "users",
+		Columns: []Column{
+			{Name: "id", Type: ColumnInt32, PrimaryKey: true},
+			{Name: "username", Type: ColumnVarchar, Size: usernameSize},
+			{Name: "email", Type: ColumnVarchar, Size: emailSize},
+		},
+	}
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, uint16(len(s)))
+	buf.WriteString(s)
+}
+
+func readString(r *bytes.Reader) (string, error) {
+	var n uint16
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+/*
+serializeInto appends this schema's catalog encoding to buf:
+name, then each column's {name, type, size, nullable, primaryKey}
+*/
+func (s *Schema) serializeInto(buf *bytes.Buffer) {
+	writeString(buf, s.TableName)
+	binary.Write(buf, binary.BigEndian, uint16(len(s.Columns)))
+	for _, col := range s.Columns {
+		writeString(buf, col.Name)
+		buf.WriteByte(byte(col.Type))
+		binary.Write(buf, binary.BigEndian, uint16(col.Size))
+		buf.WriteByte(boolByte(col.Nullable))
+		buf.WriteByte(boolByte(col.PrimaryKey))
+	}
+}
+
+func deserializeSchema(r *bytes.Reader) (*Schema, error) {
+	tableName, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	var colCount uint16
+	if err := binary.Read(r, binary.BigEndian, &colCount); err != nil {
+		return nil, err
+	}
+	columns := make([]Column, colCount)
+	for i := range columns {
+		name, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		typeByte, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		var size uint16
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		nullableByte, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		pkByte, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = Column{
+			Name:       name,
+			Type:       ColumnType(typeByte),
+			Size:       int(size),
+			Nullable:   nullableByte == 1,
+			PrimaryKey: pkByte == 1,
+		}
+	}
+	return &Schema{TableName: tableName, Columns: columns}, nil
+}
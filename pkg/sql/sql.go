@@ -3,447 +3,378 @@ package sql
 import (
 	"bytes"
 	"encoding/binary"
-	"errors"
 	"fmt"
-	"io"
-	"log"
-	"os"
-	"strconv"
 	"strings"
 )
 
 const idSize = 4
 const usernameSize = 32
 const emailSize = 255
-const idOffset = 0
-const usernameOffset = idOffset + idSize
-const emailOffset = usernameOffset + usernameSize
-const rowSize = idSize + usernameSize + emailSize
 
 /*
-Row is a wrapper for an instance of a relation in this database*/
+Row is a wrapper for an instance of a relation in this database.
+Its shape isn't fixed anymore - Values is a positional slice aligned
+with Schema.Columns, an int32 per ColumnInt32 and a string per
+ColumnVarchar/ColumnText.
+*/
 type Row struct {
-	ID       int32
-	Username [usernameSize]byte
-	Email    [emailSize]byte
+	Schema *Schema
+	Values []interface{}
 }
 
 /*
-ToString produces a representation of a row
-mostly for debugging purposes*/
-func (r *Row) ToString() string {
-	idString := strconv.Itoa(int(r.ID))
-	nameBuilder := strings.Builder{}
-	nameBuilder.Write(r.Username[0:usernameSize])
-	emailBuilder := strings.Builder{}
-	emailBuilder.Write(r.Email[0:emailSize])
-	stringComponents := []string{"Row", idString, nameBuilder.String(), emailBuilder.String()}
-	return strings.Join(stringComponents, " : ")
-}
-
-/*Serialize emits a byte stream that represents
-the canonical way to compactly store a row*/
-func (r *Row) Serialize() *bytes.Buffer {
-	rowBytes := []byte{}
-	rowBuffer := bytes.NewBuffer(rowBytes)
-	binary.Write(rowBuffer, binary.BigEndian, r.ID)
-	rowBuffer.Write(r.Username[0:usernameSize])
-	rowBuffer.Write(r.Email[0:emailSize])
-	return rowBuffer
+ID returns the row's primary key: the first column marked PrimaryKey.
+CreateTable only accepts schemas whose first such column is an int32,
+since that's also the B+Tree's key type.
+*/
+func (r *Row) ID() int32 {
+	idx, _ := r.Schema.PrimaryKeyIndex()
+	return r.Values[idx].(int32)
 }
 
 /*
-NewRow is a constructor that deals with the string
-to fixed byte array issue*/
-func NewRow(id int32, name string, email string) *Row {
-	row := &Row{ID: id}
-	for i, char := range name {
-		if i >= usernameSize {
-			break
-		}
-		row.Username[i] = byte(char)
-	}
-	for i, char := range email {
-		if i >= emailSize {
-			break
-		}
-		row.Email[i] = byte(char)
+ToString produces a representation of a row
+mostly for debugging purposes
+*/
+func (r *Row) ToString() string {
+	parts := []string{"Row"}
+	for _, value := range r.Values {
+		parts = append(parts, fmt.Sprint(value))
 	}
-	return row
+	return strings.Join(parts, " : ")
 }
 
-/*DeserializeRow will re-hydrate
-the byte array from the table store
-to an actual GoLang row object*/
-func DeserializeRow(rowBytes *[rowSize]byte) *Row {
-	row := &Row{}
-	var rowID int32
-	rowBuffer := bytes.NewBuffer(rowBytes[0:rowSize])
-	binary.Read(rowBuffer, binary.BigEndian, &rowID)
-	row.ID = rowID
-	usernameBytes := rowBuffer.Next(usernameSize)
-	for i, byteVal := range usernameBytes {
-		if i >= usernameSize {
-			break
-		}
-		row.Username[i] = byteVal
-	}
-	emailBytes := rowBuffer.Next(emailSize)
-	for i, byteVal := range emailBytes {
-		if i >= emailSize {
-			break
+/*
+Serialize emits a byte stream that represents
+the canonical way to compactly store a row, walking the schema's
+columns in order instead of a fixed id/username/email layout
+*/
+func (r *Row) Serialize() *bytes.Buffer {
+	rowBuffer := bytes.NewBuffer([]byte{})
+	for i, col := range r.Schema.Columns {
+		if col.Type == ColumnInt32 {
+			binary.Write(rowBuffer, binary.BigEndian, r.Values[i].(int32))
+			continue
 		}
-		row.Email[i] = byteVal
-	}
-	return row
-}
-
-// Table Constants for in-memory representation
-const pageConstraintSize = 4096
-const tableMaxPages = 100
-const rowsPerPage = pageConstraintSize / rowSize
-const actualPageSize = rowsPerPage * rowSize
-const tableMaxRows = rowsPerPage * tableMaxPages
-const dbFile = "./charvel.db"
-
-/*Pager is an abstraction layer for the table.
-It keeps pages cached in memory, and also knows
-how to read and write them from disk. This
-way the table can ask for a given page, and the
-Pager will take care of figuring out whether
-it's already in memory or not. */
-type Pager struct {
-	pageCache    *[tableMaxPages][actualPageSize]byte
-	cacheIndex   *map[int]bool
-	file         *os.File
-	fileReadSize int64
-}
-
-/*NewPager is the constructor for a pager
-to take care of allocating system resources for a file
-and memory space for a page cache*/
-func NewPager(dbFileName string) *Pager {
-	if dbFileName == "default" {
-		dbFileName = dbFile
-	}
-	fd, err := os.OpenFile(dbFileName, os.O_RDWR|os.O_CREATE, 0755)
-	if err != nil {
-		log.Fatal("Could not open db file: ", err)
-	}
-	fInfo, err := fd.Stat()
-	if err != nil {
-		log.Fatal("Could not get db file info: ", err)
+		fixed := make([]byte, col.width())
+		copy(fixed, []byte(r.Values[i].(string)))
+		rowBuffer.Write(fixed)
 	}
-	cIndex := make(map[int]bool)
-	return &Pager{
-		pageCache:    &[tableMaxPages][actualPageSize]byte{},
-		cacheIndex:   &cIndex,
-		file:         fd,
-		fileReadSize: fInfo.Size(),
-	}
-}
-
-/*Flush one page to disk*/
-func (p *Pager) Flush(pageIdx int, writeSize int) {
-	pageOffset := int64(pageIdx * actualPageSize)
-	p.file.Seek(pageOffset, 0)
-	pageBytes := p.pageCache[pageIdx][0:writeSize]
-	p.file.Write(pageBytes)
-}
-
-/*Close makes the underlying file close
-cleanly so we don't end up leaving
-the file un-flushed*/
-func (p *Pager) Close() {
-	p.file.Close()
+	return rowBuffer
 }
 
-/*Write sends the bytes for a record to a specific address,
-first making sure the page for the target address is loaded into memory*/
-func (p *Pager) Write(address TableAddress, rowBytes []byte) {
-	p.checkPage(address.PageNum)
-	for i, byteVal := range rowBytes {
-		if i >= rowSize {
-			break
+/*
+SerializeV2 emits a row using FormatVersion2's self-describing,
+variable-length encoding: {totalLen uint32, numFields uint16,
+(fieldLen uint32, fieldBytes...)}. Unlike Serialize, no column is
+padded or truncated to a fixed width, so VARCHAR/TEXT values round
+trip at whatever length they actually are.
+*/
+func (r *Row) SerializeV2() []byte {
+	body := bytes.NewBuffer([]byte{})
+	binary.Write(body, binary.BigEndian, uint16(len(r.Schema.Columns)))
+	for i, col := range r.Schema.Columns {
+		var field []byte
+		if col.Type == ColumnInt32 {
+			field = make([]byte, idSize)
+			binary.BigEndian.PutUint32(field, uint32(r.Values[i].(int32)))
+		} else {
+			field = []byte(r.Values[i].(string))
 		}
-		p.pageCache[address.PageNum][address.ByteOffset+i] = byteVal
-	}
-}
-
-func (p *Pager) cachePage(pageNum int) {
-	p.file.Seek(int64(pageNum*actualPageSize), 0)
-	pageBuffer := make([]byte, actualPageSize)
-	_, err := p.file.Read(pageBuffer)
-	if err != nil && err != io.EOF {
-		log.Fatal("Failed to cache page ", err)
-	}
-	for i, byteVal := range pageBuffer {
-		p.pageCache[pageNum][i] = byteVal
+		binary.Write(body, binary.BigEndian, uint32(len(field)))
+		body.Write(field)
 	}
 
+	rowBuffer := bytes.NewBuffer([]byte{})
+	binary.Write(rowBuffer, binary.BigEndian, uint32(v2RowHeaderSize+body.Len()))
+	rowBuffer.Write(body.Bytes())
+	return rowBuffer.Bytes()
 }
 
-func (p *Pager) checkPage(pageNum int) {
-	loaded, ok := (*p.cacheIndex)[pageNum]
-	if !ok || !loaded {
-		p.cachePage(pageNum)
-		(*p.cacheIndex)[pageNum] = true
-	}
-}
+const v2RowHeaderSize = 4 // totalLen itself, counted in its own value per the on-disk spec
 
-func (p *Pager) Read(address TableAddress) [rowSize]byte {
-	p.checkPage(address.PageNum)
-	rowBytes := [rowSize]byte{}
-	recByteOffset := 0
-	for {
-		if recByteOffset >= rowSize {
-			break
+/*
+DeserializeRowV2 re-hydrates a row serialized by SerializeV2,
+reading each field's own length out of the stream instead of
+relying on the schema's declared column widths.
+*/
+func DeserializeRowV2(schema *Schema, rowBytes []byte) *Row {
+	offset := v2RowHeaderSize
+	numFields := int(binary.BigEndian.Uint16(rowBytes[offset : offset+2]))
+	offset += 2
+	values := make([]interface{}, numFields)
+	for i := 0; i < numFields; i++ {
+		fieldLen := int(binary.BigEndian.Uint32(rowBytes[offset : offset+4]))
+		offset += 4
+		field := rowBytes[offset : offset+fieldLen]
+		offset += fieldLen
+		if schema.Columns[i].Type == ColumnInt32 {
+			values[i] = int32(binary.BigEndian.Uint32(field))
+		} else {
+			values[i] = string(field)
 		}
-		rowBytes[recByteOffset] = p.pageCache[address.PageNum][address.ByteOffset+recByteOffset]
-		recByteOffset++
 	}
-	return rowBytes
+	return &Row{Schema: schema, Values: values}
 }
 
 /*
-TableAddress is a simple way to pass around
-a specific memory address (offset really)
-of a row*/
-type TableAddress struct {
-	PageNum    int
-	ByteOffset int
+NewRow is a convenience constructor for the original users-shaped
+row (id/username/email) - everything else should go through
+NewRowWithSchema, which NewRow is itself built on
+*/
+func NewRow(id int32, name string, email string) *Row {
+	row, _ := NewRowWithSchema(DefaultUsersSchema(), id, name, email)
+	return row
 }
 
 /*
-Table is the storage engine, managing how
-records are serialized and deserialized
-into bytes in memory*/
-type Table struct {
-	pager   *Pager
-	numRows int
-}
-
-/*NewTable is a constructor for the table object.
-While booting, it will evaluate the table size
-from the file size on disk.*/
-func NewTable(dbFileName string) *Table {
-	pager := NewPager(dbFileName)
-	rowCount := pager.fileReadSize / rowSize
-	/*fmt.Println("TABLE LOAD: read size", pager.fileReadSize)
-	fmt.Println("TABLE LOAD: row size", rowSize)
-	fmt.Println("TABLE LOAD: row count", rowCount)*/
-	table := &Table{pager: pager, numRows: int(rowCount)}
-	return table
-}
-
-/*FetchAddress performs the conversion
-from row index to an actual address in the
-data pages with page offset and byte offset*/
-func (t *Table) FetchAddress(rowNum int) TableAddress {
-	pageNum := rowNum / rowsPerPage
-	rowsIntoPage := rowNum % rowsPerPage
-	byteOffset := rowsIntoPage * rowSize
-	return TableAddress{
-		PageNum:    pageNum,
-		ByteOffset: byteOffset,
+NewRowWithSchema builds a Row for an arbitrary table, checking that
+values lines up with the schema's column count and types
+*/
+func NewRowWithSchema(schema *Schema, values ...interface{}) (*Row, error) {
+	if len(values) != len(schema.Columns) {
+		return nil, fmt.Errorf("table %s expects %d values, got %d", schema.TableName, len(schema.Columns), len(values))
 	}
-}
-
-/*
-NextRowAddress computes where exactly to write the next
-row to in memory.  Return values are page_int, */
-func (t *Table) NextRowAddress() TableAddress {
-	return t.FetchAddress(t.numRows)
-}
-
-/*Append provides a means to persist the new row as an entry
-in the current table*/
-func (t *Table) Append(row *Row) error {
-	address := t.NextRowAddress()
-	rowBytes := row.Serialize().Bytes()
-	t.pager.Write(address, rowBytes)
-	t.numRows = t.numRows + 1
-	return nil
-}
-
-/*FetchRow knows how to find an address
-in the record pages and rehydrate
-the row object that lives there*/
-func (t *Table) FetchRow(address TableAddress) *Row {
-	rowBytes := t.pager.Read(address)
-	return DeserializeRow(&rowBytes)
-}
-
-/*ToString is mostly for debugging
-by dumping the current state of the table
-to the output*/
-func (t *Table) ToString() string {
-	rowNum := 0
-	builder := strings.Builder{}
-	builder.WriteString("Row Count: ")
-	builder.WriteString(strconv.Itoa(t.numRows))
-	builder.WriteString("\n")
-	//cur := NewCursor(t, "iterator")
-
-	for {
-		if rowNum >= t.numRows {
-			break
+	for i, col := range schema.Columns {
+		switch col.Type {
+		case ColumnInt32:
+			if _, ok := values[i].(int32); !ok {
+				return nil, fmt.Errorf("column %s expects an INT32 value", col.Name)
+			}
+		default:
+			if _, ok := values[i].(string); !ok {
+				return nil, fmt.Errorf("column %s expects a string value", col.Name)
+			}
 		}
-		address := t.FetchAddress(rowNum)
-		row := t.FetchRow(address)
-		builder.WriteString(row.ToString())
-		builder.WriteString("\n")
-		rowNum++
 	}
-	return builder.String()
-}
-
-/*Close flushes the whole table to disk
-and closes the db file*/
-func (t *Table) Close() {
-	pageCount := t.numRows / rowsPerPage
-	for i := 0; i < pageCount; i++ {
-		t.pager.Flush(i, actualPageSize)
-	}
-	extraRows := t.numRows % rowsPerPage
-	t.pager.Flush(pageCount, extraRows*rowSize)
-	t.pager.Close()
-}
-
-/*Cursor is a way to hold an offset in a table
-so you can scan forward or backward*/
-type Cursor struct {
-	Table    *Table
-	rowIndex int
-}
-
-/*GetAddress returns the address to read/write
-from on the underlying table.*/
-func (c *Cursor) GetAddress() TableAddress {
-	return c.Table.FetchAddress(c.rowIndex)
-}
-
-/*Advance just moves the cursor forward through
-the table, returning true if we're still
-within the table*/
-func (c *Cursor) Advance() bool {
-	c.rowIndex++
-	return !c.BeyondTable()
-}
-
-/*BeyondTable is true if the offset is outside
-the range of rows for which we have real data*/
-func (c *Cursor) BeyondTable() bool {
-	return c.rowIndex >= c.Table.numRows || c.rowIndex < 0
+	return &Row{Schema: schema, Values: values}, nil
 }
 
-/*NewCursor sets up the offset at the beginning
-or end of the table. The iterator mode
-offsets to -1 because it expects a for loop
-to call Advance before it's accessed anything*/
-func NewCursor(t *Table, mode string) *Cursor {
-	cursor := &Cursor{Table: t}
-	if mode == "start" {
-		cursor.rowIndex = 0
-	} else if mode == "iterator" {
-		cursor.rowIndex = -1
-	} else if mode == "end" {
-		cursor.rowIndex = t.numRows - 1
+/*
+DeserializeRow will re-hydrate the byte array from the table store
+to an actual GoLang row object, walking the schema's columns in the
+same order Serialize wrote them in
+*/
+func DeserializeRow(schema *Schema, rowBytes []byte) *Row {
+	values := make([]interface{}, len(schema.Columns))
+	offset := 0
+	for i, col := range schema.Columns {
+		width := col.width()
+		if col.Type == ColumnInt32 {
+			values[i] = int32(binary.BigEndian.Uint32(rowBytes[offset : offset+width]))
+		} else {
+			values[i] = strings.TrimRight(string(rowBytes[offset:offset+width]), "\x00")
+		}
+		offset += width
 	}
-	return cursor
+	return &Row{Schema: schema, Values: values}
 }
 
 /*
-Statement is a wrapper for
-preparaing SQL commands and paasasing them to the executor */
+Statement is a wrapper for a parsed SQL command and the context
+needed to execute it. params describes the `?`/`:name` placeholders
+Prepare found in the command, if any; bound holds the values the most
+recent Bind/BindNamed call supplied for them.
+*/
 type Statement struct {
-	raw         string
-	rowToInsert *Row
+	raw    string
+	stmt   Stmt
+	params []paramSlot
+	bound  []interface{}
 }
 
 /*
 ToString is a convenience for printing the state
-of the statement*/
+of the statement
+*/
 func (s *Statement) ToString() string {
 	return s.raw
 }
 
-func (s *Statement) isSelect() bool {
-	return s.raw[0:6] == "select"
+/*
+Prepare parses a raw SQL command into a Statement, resolving any
+`?`/`:name` placeholders into parameter slots typed against the
+target table's schema. Parse errors are returned to the caller
+instead of discovered while executing. The raw `strings.Split`
+approach this replaced broke on usernames or emails containing
+spaces, and left no barrier between a value and the SQL it was
+spliced into - placeholders fix both.
+*/
+func (e *Engine) Prepare(command string) (*Statement, error) {
+	stmt, err := Parse(command)
+	if err != nil {
+		return &Statement{raw: command}, err
+	}
+	params, err := e.collectParams(stmt)
+	if err != nil {
+		return &Statement{raw: command}, err
+	}
+	return &Statement{raw: command, stmt: stmt, params: params}, nil
 }
 
-func (s *Statement) isInsert() bool {
-	return s.raw[0:6] == "insert"
+/*
+Execute takes a statement and tries to apply it to the dataset,
+running insert/update/delete inside an implicit writable transaction
+and select inside an implicit read-only one, against whichever table
+the statement names. CREATE TABLE isn't transactional - it just
+registers the new table with the catalog. Use ExecuteInTx instead
+when the caller (e.g. the REPL's $begin/$commit meta-commands) is
+managing its own transaction.
+*/
+func (e *Engine) Execute(statement *Statement) {
+	stmt, err := statement.resolved()
+	if err == nil {
+		err = e.execStmt(stmt)
+	}
+	if err != nil {
+		fmt.Println("Could not execute statement: ", err)
+	}
 }
 
-func (s *Statement) isUpdate() bool {
-	return s.raw[0:6] == "update"
+/*
+ExecutePrepared binds args to statement's parameter slots (in
+positional order) and executes it immediately from the AST Prepare
+already parsed, without re-parsing. It's the entry point network callers
+and the REPL should use for statements with placeholders. Callers
+that already bound named parameters with BindNamed can omit args
+here; ExecutePrepared only re-binds positionally when args is non-empty.
+*/
+func (e *Engine) ExecutePrepared(statement *Statement, args ...interface{}) error {
+	if len(args) > 0 || len(statement.params) == 0 {
+		if err := statement.Bind(args...); err != nil {
+			return err
+		}
+	}
+	stmt, err := statement.resolved()
+	if err != nil {
+		return err
+	}
+	return e.execStmt(stmt)
 }
 
-func (s *Statement) isDelete() bool {
-	return s.raw[0:6] == "delete"
+/*
+stmtTableName returns the table a statement names, for the
+statement kinds that name one explicitly. CreateTableStmt's target
+lives on its Schema rather than a flat field, so it's handled here
+too instead of needing its own case at every call site.
+*/
+func stmtTableName(stmt Stmt) (string, bool) {
+	switch s := stmt.(type) {
+	case *CreateTableStmt:
+		return s.Schema.TableName, true
+	case *InsertStmt:
+		return s.Table, true
+	case *SelectStmt:
+		return s.Table, true
+	case *UpdateStmt:
+		return s.Table, true
+	case *DeleteStmt:
+		return s.Table, true
+	default:
+		return "", false
+	}
 }
 
-/*Engine keeps track of the memory state
-so that the executing functions
-can have contextual access to the
-relevant  data structures*/
-type Engine struct {
-	usersTable *Table
+/*
+ExecuteInTx applies a statement against a caller-supplied
+transaction instead of an implicit one, so interactive
+$begin/$commit/$rollback sessions can span several statements. It
+rejects a statement that names a table other than the one tx was
+opened against - without this check the statement would silently
+run against tx's table regardless of which table it claims to
+target, since tx (not the statement) is what actually picks the
+file and schema runStatement uses.
+*/
+func (e *Engine) ExecuteInTx(tx *Tx, statement *Statement) error {
+	stmt, err := statement.resolved()
+	if err != nil {
+		return err
+	}
+	if target, ok := stmtTableName(stmt); ok && target != tx.Schema().TableName {
+		return fmt.Errorf("statement targets table %s, but this transaction is against %s", target, tx.Schema().TableName)
+	}
+	return runStatement(tx, stmt)
+}
+
+func (e *Engine) execStmt(stmt Stmt) error {
+	switch s := stmt.(type) {
+	case *CreateTableStmt:
+		return e.CreateTable(s.Schema)
+	case *SelectStmt:
+		return e.View(s.Table, func(tx *Tx) error { return runStatement(tx, s) })
+	case *InsertStmt:
+		return e.Update(s.Table, func(tx *Tx) error { return runStatement(tx, s) })
+	case *UpdateStmt:
+		return e.Update(s.Table, func(tx *Tx) error { return runStatement(tx, s) })
+	case *DeleteStmt:
+		return e.Update(s.Table, func(tx *Tx) error { return runStatement(tx, s) })
+	default:
+		return fmt.Errorf("unrecognized statement: %v", stmt)
+	}
 }
 
-/*NewEngine is a standard constructor.
-It will take care of creating tablestate
-for now*/
-func NewEngine() *Engine {
-	return &Engine{usersTable: NewTable("default")}
+func runStatement(tx *Tx, stmt Stmt) error {
+	switch s := stmt.(type) {
+	case *SelectStmt:
+		return runSelect(tx, s)
+	case *InsertStmt:
+		return runInsert(tx, s)
+	case *UpdateStmt:
+		fmt.Println("Executing update...")
+		return nil
+	case *DeleteStmt:
+		fmt.Println("Executing delete")
+		return nil
+	default:
+		return fmt.Errorf("unrecognized statement")
+	}
 }
 
-/*
-Prepare is a kind of constructor for a
-SQL statement */
-func (e *Engine) Prepare(command string) (*Statement, error) {
-	lowerCommand := strings.ToLower(command)
-	statement := &Statement{
-		raw: lowerCommand,
-	}
-	if len(lowerCommand) < 6 {
-		return statement, errors.New("Unrecognized keyword at start of command: " + lowerCommand)
-	}
-	if statement.isInsert() {
-		components := strings.Split(statement.raw, " ")
-		rowID, err := strconv.Atoi(components[1])
+func runSelect(tx *Tx, stmt *SelectStmt) error {
+	fmt.Println("Executing select...")
+	switch {
+	case stmt.Where == nil:
+		fmt.Println(tx.ToString())
+	case stmt.Where.Op == whereOpEquals:
+		row, err := tx.FetchRow(stmt.Where.Value)
 		if err != nil {
-			return statement, err
+			return err
+		}
+		fmt.Println(row.ToString())
+	default: // whereOpBetween: seek to the start key and stop past High
+		cursor := NewCursorAt(tx, stmt.Where.Value)
+		for cursor.Advance() {
+			row := cursor.GetRow()
+			if row.ID() > stmt.Where.High {
+				break
+			}
+			fmt.Println(row.ToString())
 		}
-		statement.rowToInsert = NewRow(int32(rowID), components[2], components[3])
 	}
-	return statement, nil
+	return nil
 }
 
-/*
-Execute takes a statement and tries to apply
-it to the dataset*/
-func (e *Engine) Execute(statement *Statement) {
-	if statement.isSelect() {
-		fmt.Println("Executing select...")
-		fmt.Println(e.usersTable.ToString())
-	} else if statement.isInsert() {
-		fmt.Println("Inserting this row!")
-		fmt.Println(statement.rowToInsert.ToString())
-		e.usersTable.Append(statement.rowToInsert)
-	} else if statement.isUpdate() {
-		fmt.Println("Executing update...")
-	} else if statement.isDelete() {
-		fmt.Println("Executing delete")
-	} else {
-		fmt.Println("Unrecognized keyword at beginning of statement: ", statement.ToString())
+func runInsert(tx *Tx, stmt *InsertStmt) error {
+	row, err := NewRowWithSchema(tx.Schema(), stmt.Values...)
+	if err != nil {
+		return err
 	}
+	fmt.Println("Inserting this row!")
+	fmt.Println(row.ToString())
+	return tx.Insert(row)
 }
 
-/*TableStateString will return a stringified
-version of the whole table, useful for
-debugging current state*/
-func (e *Engine) TableStateString() string {
-	return e.usersTable.ToString()
+/*
+TableStateString returns a stringified version of the named
+table's state, useful for debugging current state
+*/
+func (e *Engine) TableStateString(tableName string) string {
+	var state string
+	if err := e.View(tableName, func(tx *Tx) error {
+		state = tx.ToString()
+		return nil
+	}); err != nil {
+		return err.Error()
+	}
+	return state
 }
@@ -3,18 +3,14 @@ package sql
 import (
 	"fmt"
 	"os"
-	"strings"
 	"testing"
 )
 
 const TestFileName = "./test_file.db"
 
 func ClearTestFile() {
-	_, err := os.Stat(TestFileName)
-	if os.IsNotExist(err) {
-		return
-	}
 	os.Remove(TestFileName)
+	os.Remove(walFileName(TestFileName))
 }
 
 func WriteRecords(count int, table *Table) error {
@@ -22,7 +18,7 @@ func WriteRecords(count int, table *Table) error {
 		username := fmt.Sprintf("User %d", i)
 		email := fmt.Sprintf("user.%d@test.com", i)
 		row := NewRow(int32(i), username, email)
-		err := table.Append(row)
+		err := table.Insert(row)
 		if err != nil {
 			return err
 		}
@@ -30,92 +26,141 @@ func WriteRecords(count int, table *Table) error {
 	return nil
 }
 
+func collectIDs(table *Table) []int32 {
+	ids := []int32{}
+	cursor := NewCursor(table, "iterator")
+	for cursor.Advance() {
+		ids = append(ids, cursor.GetRow().ID())
+	}
+	return ids
+}
+
 func TestPagerFileStore(t *testing.T) {
 	ClearTestFile()
-	pager := NewPager(TestFileName)
+	table := NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
 	row := NewRow(42, "testUser", "test@test.com")
-	rowBytes := row.Serialize()
-	address := TableAddress{PageNum: 0, ByteOffset: 0}
-	pager.Write(address, rowBytes.Bytes())
-	pager.Flush(0, rowSize)
-	pager.Close()
-	pager = NewPager(TestFileName)
-	readBytes := pager.Read(address)
-	readRow := DeserializeRow(&readBytes)
-	if readRow.ID != row.ID {
+	err := table.Insert(row)
+	if err != nil {
+		t.Error("Failed to insert row", err)
+	}
+	table.Close()
+
+	table = NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
+	readRow, err := table.FetchRow(42)
+	if err != nil {
+		t.Error("Failed to fetch row after reopen", err)
+	}
+	if readRow.ID() != row.ID() {
 		t.Error("pager should persist to disk")
 	}
 	ClearTestFile()
 }
 
-func TestReadingRowcount(t *testing.T) {
+func TestInsertAndFetchRow(t *testing.T) {
 	ClearTestFile()
-	table := NewTable(TestFileName)
-	if table.numRows != 0 {
-		t.Error("Blank file should count as 0 rows")
+	table := NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
+	err := WriteRecords(30, table)
+	if err != nil {
+		t.Error("Failed to insert records", err)
 	}
-	rowCount := 200
-	err := WriteRecords(rowCount, table)
+	row, err := table.FetchRow(16)
 	if err != nil {
-		t.Error("Failed to append records", err)
+		t.Error("Failed to fetch row 16", err)
 	}
-	if table.numRows != rowCount {
-		t.Error("Did not persist correct row count: ", table.numRows)
+	if username := row.Values[1].(string); username != "User 16" {
+		t.Error("Loaded wrong row for 16: ", username)
 	}
-	table.Close()
-	table = NewTable(TestFileName)
-	if table.numRows != rowCount {
-		t.Error("Did not recover rowcount from file: ", table.numRows)
+	if _, err := table.FetchRow(999); err != ErrRowNotFound {
+		t.Error("Expected ErrRowNotFound for missing key, got", err)
 	}
 	ClearTestFile()
 }
 
-func TestAddressFetching(t *testing.T) {
+func TestDuplicateKeyRejected(t *testing.T) {
 	ClearTestFile()
-	table := NewTable(TestFileName)
-	row1Address := table.FetchAddress(0)
-	if row1Address.PageNum != 0 || row1Address.ByteOffset != 0 {
-		t.Error("Row Offset for addressing is off: ", row1Address)
+	table := NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
+	if err := table.Insert(NewRow(1, "first", "first@test.com")); err != nil {
+		t.Error("Failed to insert first row", err)
+	}
+	err := table.Insert(NewRow(1, "second", "second@test.com"))
+	if err != ErrDuplicateKey {
+		t.Error("Expected ErrDuplicateKey, got", err)
 	}
+	ClearTestFile()
 }
 
-func TestTableString(t *testing.T) {
+func TestOrderedIterationAfterRandomInsert(t *testing.T) {
 	ClearTestFile()
-	table := NewTable(TestFileName)
-	err := WriteRecords(5, table)
-	if err != nil {
-		t.Error("Failed to append records", err)
+	table := NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
+	order := []int32{50, 10, 90, 30, 70, 20, 80, 40, 60, 1, 100}
+	for _, id := range order {
+		row := NewRow(id, fmt.Sprintf("User %d", id), fmt.Sprintf("user.%d@test.com", id))
+		if err := table.Insert(row); err != nil {
+			t.Error("Failed to insert row", id, err)
+		}
+	}
+	ids := collectIDs(table)
+	if len(ids) != len(order) {
+		t.Error("Expected all rows to be iterated, got", len(ids))
 	}
-	tableState := table.ToString()
-	if !strings.Contains(tableState, "User 2") {
-		t.Error("table should have 5 records: ", tableState)
+	for i := 1; i < len(ids); i++ {
+		if ids[i-1] >= ids[i] {
+			t.Error("Rows are not in ascending key order: ", ids)
+		}
+	}
+	ClearTestFile()
+}
+
+func TestNewCursorAtSeeksPastLeadingLeaves(t *testing.T) {
+	ClearTestFile()
+	table := NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
+	defer ClearTestFile()
+	rowCount := 500 // enough leaves that a leading scan vs. a seek would disagree
+	if err := WriteRecords(rowCount, table); err != nil {
+		t.Error("Failed to insert records", err)
+	}
+
+	cursor := NewCursorAt(table, 480)
+	ids := []int32{}
+	for cursor.Advance() {
+		ids = append(ids, cursor.GetRow().ID())
 	}
-	if strings.Contains(tableState, "User 6") {
-		t.Error("Table should have cut off at 5", tableState)
+	if len(ids) != rowCount-480+1 {
+		t.Error("Expected rows from 480 through", rowCount, "got", len(ids))
+	}
+	if ids[0] != 480 {
+		t.Error("Expected the cursor to seek straight to 480, got", ids[0])
 	}
 }
 
-func TestReadingFromOtherPages(t *testing.T) {
+func TestSplitCascades(t *testing.T) {
 	ClearTestFile()
-	table := NewTable(TestFileName)
-	err := WriteRecords(30, table)
+	table := NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
+	// Large enough to force many leaf splits and a new root; an internal
+	// node holds up to internalMaxCells children, so none splits here.
+	rowCount := 500
+	err := WriteRecords(rowCount, table)
 	if err != nil {
-		t.Error("Failed to append records", err)
+		t.Error("Failed to insert records", err)
 	}
-	table.Close()
-	table = NewTable(TestFileName)
-	row1 := table.FetchRow(TableAddress{PageNum: 0, ByteOffset: 0})
-	if row1.ID != 1 {
-		t.Error("loaded wrong row", row1)
-	}
-	row16Address := table.FetchAddress(15)
-	if row16Address.PageNum != 1 {
-		t.Error("Address matching should have hit next page")
-	}
-	row16 := table.FetchRow(row16Address)
-	for i := 0; i <= 6; i++ {
-		if row16.Username[i] != []byte("User 16")[i] {
-			t.Error("Loaded wrong row for 16: ", string(row16.Username[0:32]))
+	ids := collectIDs(table)
+	if len(ids) != rowCount {
+		t.Error("Expected ", rowCount, " rows after split cascade, got ", len(ids))
+	}
+	for i := 1; i <= rowCount; i++ {
+		if ids[i-1] != int32(i) {
+			t.Error("Expected ascending contiguous ids, found ", ids[i-1], " at position ", i-1)
 		}
 	}
+	table.Close()
+
+	table = NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
+	row, err := table.FetchRow(int32(rowCount))
+	if err != nil {
+		t.Error("Failed to fetch last row after reopen", err)
+	}
+	if row.ID() != int32(rowCount) {
+		t.Error("Did not recover last row after split cascade and reopen: ", row.ID())
+	}
+	ClearTestFile()
 }
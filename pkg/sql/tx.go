@@ -0,0 +1,349 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+)
+
+/*
+ErrTxDone is returned by Commit/Rollback when the transaction has
+already been committed or rolled back once
+*/
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+/*
+Tx is a handle on a single transaction against one table, modeled on
+bbolt's Tx: writable transactions snapshot the root page number at
+Begin, copy any page they touch into a private dirty set, and only
+on Commit are those pages applied back into the pager and flushed to
+disk. Read-only transactions take a private snapshot of every page
+that exists at Begin, so they keep seeing the root (and every page
+reachable from it) exactly as it stood at Begin for the transaction's
+whole lifetime - not just "while a writer happens to still be in
+progress" - even once a writer has since committed pages that, in
+the live pager cache, would otherwise have been overwritten in place.
+*/
+type Tx struct {
+	pager    *Pager
+	schema   *Schema
+	writable bool
+	root     int
+	dirty    map[int]*[pageSize]byte
+	snapshot map[int]*[pageSize]byte
+	done     bool
+	txID     uint64
+}
+
+func newTx(pager *Pager, schema *Schema, writable bool) *Tx {
+	tx := &Tx{
+		pager:    pager,
+		schema:   schema,
+		writable: writable,
+		root:     pager.RootPageNum(),
+		dirty:    make(map[int]*[pageSize]byte),
+		txID:     pager.nextTxID(),
+	}
+	if !writable {
+		tx.snapshot = make(map[int]*[pageSize]byte, pager.pageCount())
+		for pageNum := fileHeaderPageNum; pageNum < pager.pageCount(); pageNum++ {
+			copied := *pager.GetPage(pageNum)
+			tx.snapshot[pageNum] = &copied
+		}
+	}
+	return tx
+}
+
+/*
+GetPage returns the transaction's private copy of a page. A writable
+Tx clones a page out of the live pager the first time it touches it;
+a read-only Tx instead reads out of the snapshot newTx took at Begin,
+so a writer committing in the meantime can never change what it sees.
+*/
+func (tx *Tx) GetPage(pageNum int) *[pageSize]byte {
+	if page, ok := tx.dirty[pageNum]; ok {
+		return page
+	}
+	if !tx.writable {
+		return tx.snapshot[pageNum]
+	}
+	copied := *tx.pager.GetPage(pageNum)
+	tx.dirty[pageNum] = &copied
+	return tx.dirty[pageNum]
+}
+
+/*
+AllocatePage hands out a fresh page number from the pager and
+seeds it with a blank page in this transaction's private dirty
+set, invisible to everyone else until Commit.
+*/
+func (tx *Tx) AllocatePage() int {
+	pageNum := tx.pager.AllocatePage()
+	var blank [pageSize]byte
+	tx.dirty[pageNum] = &blank
+	return pageNum
+}
+
+func (tx *Tx) markDirty(pageNum int) {
+	// No-op: GetPage already copied this page into tx.dirty, and that
+	// is the only bookkeeping Commit needs.
+}
+
+/*
+RootPageNum returns the root page number as it stood when this
+transaction began, not the pager's current (possibly newer) root.
+*/
+func (tx *Tx) RootPageNum() int { return tx.root }
+
+/*
+SetRootPageNum records a new root for this transaction only; it
+becomes the pager's root for real once Commit succeeds.
+*/
+func (tx *Tx) SetRootPageNum(pageNum int) { tx.root = pageNum }
+
+/*
+Schema returns the schema of the table this transaction is
+against
+*/
+func (tx *Tx) Schema() *Schema { return tx.schema }
+
+/*FormatVersion reports the on-disk row format of the table this transaction is against*/
+func (tx *Tx) FormatVersion() int { return tx.pager.FormatVersion() }
+
+/*IncrementRowCount bumps the underlying pager's persisted row count*/
+func (tx *Tx) IncrementRowCount() { tx.pager.IncrementRowCount() }
+
+/*Insert adds a row to the tree as seen by this transaction*/
+func (tx *Tx) Insert(row *Row) error { return insertRow(tx, row) }
+
+/*FetchRow looks up a row by id as seen by this transaction*/
+func (tx *Tx) FetchRow(id int32) (*Row, error) { return fetchRow(tx, id) }
+
+/*ToString dumps the rows visible to this transaction, in key order*/
+func (tx *Tx) ToString() string { return storeToString(tx) }
+
+/*
+Commit makes every page this transaction touched durable by
+appending a WAL record (before/after image, fsynced) for each one,
+in ascending page-number order, before applying them into the
+pager's in-memory cache - at that point the transaction has
+survived a crash even though the main db file hasn't been touched
+yet, since Pager.Checkpoint can always redo these records later.
+The header page's root-pointer update goes through this same WAL
+record set instead of being flushed to the db file directly: if it
+weren't, a crash after the regular pages' WAL records were fsynced
+but before the header flush would leave a new root physically on
+disk with nothing durable pointing at it, since replay has no record
+to recover the pointer from. A read-only Commit is a no-op.
+*/
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	if !tx.writable {
+		return nil
+	}
+
+	pageNums := make([]int, 0, len(tx.dirty))
+	for pageNum := range tx.dirty {
+		pageNums = append(pageNums, pageNum)
+	}
+	sort.Ints(pageNums)
+
+	headerBefore := tx.pager.pageCache[fileHeaderPageNum]
+	headerAfter := headerBefore
+	setRootPageNumInPage(&headerAfter, tx.root)
+
+	for _, pageNum := range pageNums {
+		before := tx.pager.pageCache[pageNum]
+		after := *tx.dirty[pageNum]
+		if _, err := tx.pager.wal.append(tx.txID, pageNum, &before, &after); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.pager.wal.append(tx.txID, fileHeaderPageNum, &headerBefore, &headerAfter); err != nil {
+		return err
+	}
+
+	for _, pageNum := range pageNums {
+		tx.pager.pageCache[pageNum] = *tx.dirty[pageNum]
+		tx.pager.markDirty(pageNum)
+	}
+	tx.pager.pageCache[fileHeaderPageNum] = headerAfter
+	tx.pager.markDirty(fileHeaderPageNum)
+
+	return nil
+}
+
+/*
+Rollback discards this transaction's dirty set without touching
+the pager at all. It is safe (and required, to release the
+transaction) to call on a read-only Tx too.
+*/
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.dirty = nil
+	return nil
+}
+
+/*
+Engine keeps track of the memory state so that the executing
+functions can have contextual access to the relevant data
+structures. It holds one *Table per table the catalog knows about,
+loaded from the catalog at startup, plus whatever CREATE TABLE
+statements have registered since.
+*/
+type Engine struct {
+	catalog              *catalog
+	entries              map[string]*catalogEntry
+	tables               map[string]*Table
+	defaultFormatVersion int
+}
+
+/*
+NewEngine is a standard constructor. It opens the bootstrap
+catalog and rebuilds a *Table for every entry it finds, so a
+restarted Engine sees the same tables (with the same schemas) it
+had before. defaultFormatVersion is only consulted for tables this
+Engine creates from here on (existing files carry their own format
+version in their header, which NewPager always honors over it).
+*/
+func NewEngine(defaultFormatVersion int) *Engine {
+	cat := openCatalog(catalogFile)
+	entries, err := cat.load()
+	if err != nil {
+		log.Fatal("Could not load catalog: ", err)
+	}
+	tables := make(map[string]*Table, len(entries))
+	for name, entry := range entries {
+		tables[name] = NewTable(entry.fileName, entry.schema, defaultFormatVersion)
+	}
+	return &Engine{catalog: cat, entries: entries, tables: tables, defaultFormatVersion: defaultFormatVersion}
+}
+
+/*
+CreateTable registers a new table with the engine: it allocates a
+data file named after the table, adds it to the in-memory table map,
+and persists the schema to the catalog so it survives a restart.
+*/
+func (e *Engine) CreateTable(schema *Schema) error {
+	if _, exists := e.tables[schema.TableName]; exists {
+		return fmt.Errorf("table already exists: %s", schema.TableName)
+	}
+	idx, err := schema.PrimaryKeyIndex()
+	if err != nil {
+		return err
+	}
+	if schema.Columns[idx].Type != ColumnInt32 {
+		return fmt.Errorf("primary key column %q must be INT32", schema.Columns[idx].Name)
+	}
+	fileName := "./" + schema.TableName + ".db"
+	e.tables[schema.TableName] = NewTable(fileName, schema, e.defaultFormatVersion)
+	e.entries[schema.TableName] = &catalogEntry{fileName: fileName, schema: schema}
+	return e.catalog.save(e.entries)
+}
+
+/*
+Begin starts a new transaction against the named table. Callers
+are responsible for calling Commit or Rollback exactly once - prefer
+Update/View, which do this for you.
+*/
+func (e *Engine) Begin(tableName string, writable bool) (*Tx, error) {
+	table, ok := e.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("no such table: %s", tableName)
+	}
+	return newTx(table.pager, table.schema, writable), nil
+}
+
+/*
+Update runs fn inside a writable transaction against tableName,
+committing its changes if fn returns nil and rolling back otherwise
+*/
+func (e *Engine) Update(tableName string, fn func(tx *Tx) error) error {
+	tx, err := e.Begin(tableName, true)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+View runs fn inside a read-only transaction against tableName and
+always rolls it back afterward, since a read-only Tx has nothing to
+commit
+*/
+func (e *Engine) View(tableName string, fn func(tx *Tx) error) error {
+	tx, err := e.Begin(tableName, false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+/*
+Migrate rewrites tableName's data file from FormatVersion1 to
+FormatVersion2, streaming every row through a temp file (so a crash
+mid-migration leaves the original file untouched) and swapping it
+(and its WAL, which starts fresh for the migrated file) into place
+with an atomic os.Rename once every row has copied over cleanly.
+*/
+func (e *Engine) Migrate(tableName string) error {
+	table, ok := e.tables[tableName]
+	if !ok {
+		return fmt.Errorf("no such table: %s", tableName)
+	}
+	if table.FormatVersion() == FormatVersion2 {
+		return fmt.Errorf("table %s is already FormatVersion2", tableName)
+	}
+
+	entry := e.entries[tableName]
+	tmpFileName := entry.fileName + ".migrating"
+	os.Remove(tmpFileName)
+	os.Remove(walFileName(tmpFileName))
+	migrated := NewTable(tmpFileName, entry.schema, FormatVersion2)
+
+	cursor := NewCursor(table, "iterator")
+	for cursor.Advance() {
+		if err := migrated.Insert(cursor.GetRow()); err != nil {
+			migrated.Close()
+			os.Remove(tmpFileName)
+			os.Remove(walFileName(tmpFileName))
+			return err
+		}
+	}
+	migrated.Close()
+	table.Close()
+
+	if err := os.Rename(tmpFileName, entry.fileName); err != nil {
+		return err
+	}
+	if err := os.Rename(walFileName(tmpFileName), walFileName(entry.fileName)); err != nil {
+		return err
+	}
+
+	e.tables[tableName] = NewTable(entry.fileName, entry.schema, FormatVersion2)
+	return nil
+}
+
+/*
+Close flushes and closes every table the engine has open, along
+with the catalog
+*/
+func (e *Engine) Close() {
+	for _, table := range e.tables {
+		table.Close()
+	}
+	e.catalog.close()
+}
@@ -0,0 +1,187 @@
+package sql
+
+import (
+	"testing"
+)
+
+func newTestEngine(t *testing.T) *Engine {
+	ClearTestFile()
+	t.Cleanup(ClearTestFile)
+	schema := DefaultUsersSchema()
+	return &Engine{
+		entries: map[string]*catalogEntry{schema.TableName: {fileName: TestFileName, schema: schema}},
+		tables:  map[string]*Table{schema.TableName: NewTable(TestFileName, schema, FormatVersion1)},
+	}
+}
+
+func TestUpdateCommitsRowsVisibleAfterwards(t *testing.T) {
+	engine := newTestEngine(t)
+	err := engine.Update("users", func(tx *Tx) error {
+		return tx.Insert(NewRow(1, "committed", "committed@test.com"))
+	})
+	if err != nil {
+		t.Error("Update should have succeeded", err)
+	}
+
+	err = engine.View("users", func(tx *Tx) error {
+		row, err := tx.FetchRow(1)
+		if err != nil {
+			return err
+		}
+		if row.ID() != 1 {
+			t.Error("Expected committed row to be visible", row)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error("Committed row should be fetchable", err)
+	}
+}
+
+func TestUpdateRollsBackOnError(t *testing.T) {
+	engine := newTestEngine(t)
+	sentinelErr := ErrDuplicateKey
+	err := engine.Update("users", func(tx *Tx) error {
+		if err := tx.Insert(NewRow(1, "rolled back", "rolledback@test.com")); err != nil {
+			return err
+		}
+		return sentinelErr
+	})
+	if err != sentinelErr {
+		t.Error("Expected Update to surface the callback's error", err)
+	}
+
+	err = engine.View("users", func(tx *Tx) error {
+		_, fetchErr := tx.FetchRow(1)
+		if fetchErr != ErrRowNotFound {
+			t.Error("Row from a rolled-back transaction should not be visible", fetchErr)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error("View should have succeeded", err)
+	}
+}
+
+func TestReaderSeesStableRootWhileWriterInProgress(t *testing.T) {
+	engine := newTestEngine(t)
+	if err := engine.Update("users", func(tx *Tx) error {
+		return tx.Insert(NewRow(1, "before", "before@test.com"))
+	}); err != nil {
+		t.Error("Failed to seed initial row", err)
+	}
+
+	reader, err := engine.Begin("users", false)
+	if err != nil {
+		t.Error("Failed to begin reader", err)
+	}
+	defer reader.Rollback()
+
+	writer, err := engine.Begin("users", true)
+	if err != nil {
+		t.Error("Failed to begin writer", err)
+	}
+	if err := writer.Insert(NewRow(2, "during", "during@test.com")); err != nil {
+		t.Error("Failed to insert during writer tx", err)
+	}
+
+	if _, err := reader.FetchRow(2); err != ErrRowNotFound {
+		t.Error("Reader begun before the writer should not see its uncommitted row", err)
+	}
+
+	if err := writer.Commit(); err != nil {
+		t.Error("Failed to commit writer", err)
+	}
+}
+
+/*
+TestReaderStaysStableAfterWriterCommits is the same setup as
+TestReaderSeesStableRootWhileWriterInProgress, but checks the reader
+again after the writer commits instead of only before. A reader's
+snapshot should hold for its whole lifetime, not just "while the
+writer happens to still be in progress" - the writer committing a
+page the reader has already opened (the same leaf, in the common
+no-split case) must not change what the reader sees.
+*/
+func TestReaderStaysStableAfterWriterCommits(t *testing.T) {
+	engine := newTestEngine(t)
+	if err := engine.Update("users", func(tx *Tx) error {
+		return tx.Insert(NewRow(1, "before", "before@test.com"))
+	}); err != nil {
+		t.Error("Failed to seed initial row", err)
+	}
+
+	reader, err := engine.Begin("users", false)
+	if err != nil {
+		t.Error("Failed to begin reader", err)
+	}
+	defer reader.Rollback()
+
+	if err := engine.Update("users", func(tx *Tx) error {
+		return tx.Insert(NewRow(2, "during", "during@test.com"))
+	}); err != nil {
+		t.Error("Failed to insert and commit second row", err)
+	}
+
+	if _, err := reader.FetchRow(2); err != ErrRowNotFound {
+		t.Error("Reader's snapshot should still exclude a row committed after Begin, even after that commit", err)
+	}
+	if _, err := reader.FetchRow(1); err != nil {
+		t.Error("Reader should still see the row present at Begin", err)
+	}
+}
+
+/*
+TestRootPointerSurvivesCrashBeforeCheckpoint simulates a crash
+between a commit's WAL append and the next checkpoint - exactly the
+window Commit's header record exists to cover - by appending a
+root-pointer-changing header record straight to the WAL (the same
+thing Commit itself does for a split) and never checkpointing it.
+Reopening the file has only the WAL to recover the new root from,
+since the main db file on disk still has the old one.
+*/
+func TestRootPointerSurvivesCrashBeforeCheckpoint(t *testing.T) {
+	engine := newTestEngine(t)
+	if err := engine.Update("users", func(tx *Tx) error {
+		return tx.Insert(NewRow(1, "before", "before@test.com"))
+	}); err != nil {
+		t.Error("Failed to seed initial row", err)
+	}
+
+	table := engine.tables["users"]
+	pager := table.pager
+	if err := pager.Checkpoint(); err != nil {
+		t.Fatal("Failed to establish an on-disk baseline", err)
+	}
+	oldRoot := pager.RootPageNum()
+	newRoot := pager.AllocatePage()
+
+	before := pager.pageCache[fileHeaderPageNum]
+	after := before
+	setRootPageNumInPage(&after, newRoot)
+	if _, err := pager.wal.append(pager.nextTxID(), fileHeaderPageNum, &before, &after); err != nil {
+		t.Fatal("Failed to append header WAL record", err)
+	}
+
+	// Crash: reopen without ever checkpointing, so recovery has to
+	// replay the WAL record above to see the new root at all.
+	reopened := NewTable(TestFileName, DefaultUsersSchema(), FormatVersion1)
+	defer reopened.Close()
+	if got := reopened.RootPageNum(); got != newRoot {
+		t.Errorf("Expected recovery to replay the header's root pointer to %d, got %d (old root was %d)", newRoot, got, oldRoot)
+	}
+}
+
+func TestCommitAfterCommitReturnsErrTxDone(t *testing.T) {
+	engine := newTestEngine(t)
+	tx, err := engine.Begin("users", true)
+	if err != nil {
+		t.Error("Failed to begin transaction", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Error("First commit should succeed", err)
+	}
+	if err := tx.Commit(); err != ErrTxDone {
+		t.Error("Second commit should return ErrTxDone", err)
+	}
+}
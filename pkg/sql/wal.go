@@ -0,0 +1,178 @@
+package sql
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+)
+
+/*ErrCorrupted reports that some persisted state - a WAL record's
+CRC, or (via the header's own validateHeader) a bad magic/version -
+didn't match what was written. A short read at the end of the WAL is
+not reported as ErrCorrupted: it is a torn write caused by a clean
+crash, which replayWAL tolerates as the end of the log. It exists so
+callers can tell the two apart, in the style of goleveldb's
+corruption error.*/
+type ErrCorrupted struct {
+	reason string
+}
+
+func (e *ErrCorrupted) Error() string { return "charvel: corrupted: " + e.reason }
+
+func newErrCorrupted(reason string) error { return &ErrCorrupted{reason: reason} }
+
+/*IsCorrupted reports whether err is (or wraps) an ErrCorrupted*/
+func IsCorrupted(err error) bool {
+	var corrupted *ErrCorrupted
+	return errors.As(err, &corrupted)
+}
+
+/*errWALTorn marks a WAL record that was only partially written -
+the tail left behind by a crash mid-append. Unlike ErrCorrupted,
+replayWAL treats this as the normal end of the log, not a failure.*/
+var errWALTorn = errors.New("torn WAL record")
+
+// A WAL record is {lsn, txID, pageNum, beforeImage, afterImage, crc32}.
+// lsn doubles as the record's own byte offset in the WAL file, the
+// same trick real write-ahead logs (e.g. Postgres) use so "have I
+// applied up to LSN x" is just "have I read up to byte x".
+const (
+	walLSNSize     = 8
+	walTxIDSize    = 8
+	walPageNumSize = 4
+	walImageSize   = pageSize
+	walCRCSize     = 4
+	walRecordSize  = walLSNSize + walTxIDSize + walPageNumSize + walImageSize*2 + walCRCSize
+)
+
+const walFile = "./charvel.wal"
+
+func walFileName(dbFileName string) string {
+	if dbFileName == dbFile {
+		return walFile
+	}
+	return dbFileName + "-wal"
+}
+
+/*wal is the append-only redo log backing Pager/Tx: every page a
+writable Tx touches gets one record here, fsynced, before that
+page is considered committed - even though the main db file isn't
+written back to until the next checkpoint.*/
+type wal struct {
+	file *os.File
+}
+
+func openWAL(path string) *wal {
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		log.Fatal("Could not open WAL file: ", err)
+	}
+	return &wal{file: fd}
+}
+
+func (w *wal) close() {
+	w.file.Close()
+}
+
+/*append writes one record to the end of the log and fsyncs it,
+returning the lsn (byte offset) the record was written at*/
+func (w *wal) append(txID uint64, pageNum int, before, after *[pageSize]byte) (uint64, error) {
+	offset, err := w.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, walRecordSize)
+	off := 0
+	binary.BigEndian.PutUint64(buf[off:], uint64(offset))
+	off += walLSNSize
+	binary.BigEndian.PutUint64(buf[off:], txID)
+	off += walTxIDSize
+	binary.BigEndian.PutUint32(buf[off:], uint32(pageNum))
+	off += walPageNumSize
+	copy(buf[off:off+walImageSize], before[:])
+	off += walImageSize
+	copy(buf[off:off+walImageSize], after[:])
+	off += walImageSize
+	crc := crc32.ChecksumIEEE(buf[0:off])
+	binary.BigEndian.PutUint32(buf[off:], crc)
+
+	if _, err := w.file.Write(buf); err != nil {
+		return 0, err
+	}
+	if err := w.file.Sync(); err != nil {
+		return 0, err
+	}
+	return uint64(offset), nil
+}
+
+type walRecord struct {
+	lsn     uint64
+	txID    uint64
+	pageNum int
+	after   [pageSize]byte
+}
+
+/*readRecordAt parses the record starting at offset, returning the
+offset just past it. A zero-length read means a clean end of log; a
+short read means the tail record was torn by a crash mid-append
+(errWALTorn); a full-length read with a bad CRC means real
+corruption (ErrCorrupted).*/
+func (w *wal) readRecordAt(offset int64) (*walRecord, int64, error) {
+	buf := make([]byte, walRecordSize)
+	n, err := w.file.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	if n == 0 {
+		return nil, offset, nil
+	}
+	if n < walRecordSize {
+		return nil, 0, errWALTorn
+	}
+
+	off := 0
+	rec := &walRecord{}
+	rec.lsn = binary.BigEndian.Uint64(buf[off:])
+	off += walLSNSize
+	rec.txID = binary.BigEndian.Uint64(buf[off:])
+	off += walTxIDSize
+	rec.pageNum = int(binary.BigEndian.Uint32(buf[off:]))
+	off += walPageNumSize
+	off += walImageSize // beforeImage isn't needed for redo replay
+	copy(rec.after[:], buf[off:off+walImageSize])
+	off += walImageSize
+	storedCRC := binary.BigEndian.Uint32(buf[off:])
+
+	if computedCRC := crc32.ChecksumIEEE(buf[0:off]); storedCRC != computedCRC {
+		return nil, 0, newErrCorrupted("WAL record CRC mismatch at offset")
+	}
+	return rec, offset + walRecordSize, nil
+}
+
+/*replayWAL walks records from fromOffset forward, calling apply
+with each record's page number and after-image, and stops cleanly
+(nil error) at a clean end of log or a torn tail record. A CRC
+mismatch on a full-length record is real corruption and is
+returned as an ErrCorrupted instead of being silently swallowed.
+It returns the offset replay stopped at.*/
+func replayWAL(w *wal, fromOffset int64, apply func(pageNum int, after *[pageSize]byte)) (int64, error) {
+	offset := fromOffset
+	for {
+		rec, next, err := w.readRecordAt(offset)
+		if err == errWALTorn {
+			return offset, nil
+		}
+		if err != nil {
+			return offset, err
+		}
+		if rec == nil {
+			return offset, nil
+		}
+		apply(rec.pageNum, &rec.after)
+		offset = next
+	}
+}
@@ -0,0 +1,103 @@
+package sql
+
+import (
+	"os"
+	"testing"
+)
+
+const testWALFileName = "./test_wal.wal"
+
+func clearTestWAL() {
+	os.Remove(testWALFileName)
+}
+
+func writeTestRecords(t *testing.T, w *wal, count int) {
+	for i := 0; i < count; i++ {
+		var before, after [pageSize]byte
+		after[0] = byte(i)
+		if _, err := w.append(1, i, &before, &after); err != nil {
+			t.Fatal("Failed to append WAL record", err)
+		}
+	}
+}
+
+func TestReplayWALAppliesAllRecordsInOrder(t *testing.T) {
+	clearTestWAL()
+	defer clearTestWAL()
+	w := openWAL(testWALFileName)
+	defer w.close()
+	writeTestRecords(t, w, 5)
+
+	applied := []int{}
+	offset, err := replayWAL(w, 0, func(pageNum int, after *[pageSize]byte) {
+		applied = append(applied, pageNum)
+	})
+	if err != nil {
+		t.Error("Replay of a clean log should not error", err)
+	}
+	if len(applied) != 5 {
+		t.Error("Expected 5 records replayed, got ", len(applied))
+	}
+	for i, pageNum := range applied {
+		if pageNum != i {
+			t.Error("Records should replay in append order: ", applied)
+		}
+	}
+	if offset == 0 {
+		t.Error("Expected replay to advance past offset 0")
+	}
+}
+
+func TestReplayWALToleratesTornTailRecord(t *testing.T) {
+	clearTestWAL()
+	defer clearTestWAL()
+	w := openWAL(testWALFileName)
+	writeTestRecords(t, w, 3)
+	// Simulate a crash mid-append: truncate the last record's tail off.
+	fullSize, err := w.file.Seek(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.file.Truncate(fullSize - walRecordSize/2); err != nil {
+		t.Fatal(err)
+	}
+	w.close()
+
+	w = openWAL(testWALFileName)
+	defer w.close()
+	applied := []int{}
+	_, err = replayWAL(w, 0, func(pageNum int, after *[pageSize]byte) {
+		applied = append(applied, pageNum)
+	})
+	if err != nil {
+		t.Error("A torn tail record should be tolerated, not returned as an error", err)
+	}
+	if len(applied) != 2 {
+		t.Error("Expected only the two complete records to replay, got ", len(applied))
+	}
+}
+
+func TestReplayWALDetectsCorruptedRecord(t *testing.T) {
+	clearTestWAL()
+	defer clearTestWAL()
+	w := openWAL(testWALFileName)
+	writeTestRecords(t, w, 3)
+	// Flip a byte inside the second record's after-image without
+	// changing the file's length - a full-length record with a bad
+	// CRC, unlike the short read a torn tail produces.
+	flipOffset := int64(walRecordSize) + walLSNSize + walTxIDSize + walPageNumSize + walImageSize + 10
+	if _, err := w.file.WriteAt([]byte{0xFF}, flipOffset); err != nil {
+		t.Fatal(err)
+	}
+	w.close()
+
+	w = openWAL(testWALFileName)
+	defer w.close()
+	_, err := replayWAL(w, 0, func(pageNum int, after *[pageSize]byte) {})
+	if err == nil {
+		t.Error("Expected a corruption error for a flipped byte in a full-length record")
+	}
+	if !IsCorrupted(err) {
+		t.Error("Expected IsCorrupted(err) to be true, got ", err)
+	}
+}